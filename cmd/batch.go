@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/hambosto/go-encryption/internal/core"
+	"github.com/hambosto/go-encryption/internal/credential"
+	"github.com/hambosto/go-encryption/internal/progress"
+)
+
+// runBatch applies op to several files with one password, carrying on past
+// files that fail, and ends with a summary.
+func runBatch(opts *operationFlags, files []string, op core.OperationType) {
+	if opts.output != "" {
+		fmt.Fprintf(os.Stderr, "Error: -o cannot be used with several files\n")
+		os.Exit(exitUsage)
+	}
+	if slices.Contains(files, "-") {
+		fmt.Fprintf(os.Stderr, "Error: stdin cannot be part of several files\n")
+		os.Exit(exitUsage)
+	}
+
+	configs := make([]core.OperationConfig, len(files))
+	for i, file := range files {
+		config, err := opts.config(file, op)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitUsage)
+		}
+		configs[i] = config
+	}
+	shareProgress(configs, opts.jobs)
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	prompt := flagPrompt{password: opts.password, force: opts.force, deletePolicy: opts.deletePolicy}
+	typed, err := batchPasswords(configs, opts, prompt)
+	if err != nil {
+		exitOnError(ctx, err)
+	}
+
+	results := core.NewOperation(core.NewFileManager(3), prompt).ProcessBatch(ctx, configs, opts.jobs)
+	fmt.Fprintln(os.Stderr)
+	failed := printSummary(os.Stderr, results)
+
+	if opts.credentialHelper != "" && opts.credentialStore {
+		helper := credential.NewHelper(opts.credentialHelper)
+		for i, result := range results {
+			if result.Err != nil || !typed[i] || configs[i].Operation != core.OperationEncrypt {
+				continue
+			}
+			if err := helper.Store(credentialRequest(result.Input, result.Output, true), configs[i].Password); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}
+	}
+
+	if ctx.Err() != nil {
+		os.Exit(exitInterrupted)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// batchPasswords fills in the password of every config before anything
+// runs: from a --password-* source, else from the credential helper, else
+// from a single prompt. It reports which configs got the typed password.
+func batchPasswords(configs []core.OperationConfig, opts *operationFlags, prompt core.PromptInterface) ([]bool, error) {
+	typed := make([]bool, len(configs))
+	if !opts.password.interactive() {
+		password, err := opts.password.read()
+		if err != nil {
+			return nil, err
+		}
+		for i := range configs {
+			configs[i].Password = password
+		}
+		return typed, nil
+	}
+
+	if opts.credentialHelper != "" {
+		helper := credential.NewHelper(opts.credentialHelper)
+		for i, config := range configs {
+			password, err := helper.Get(credentialRequest(config.InputPath, config.OutputPath, config.Operation == core.OperationEncrypt))
+			if err != nil {
+				return nil, err
+			}
+			configs[i].Password = password
+		}
+	}
+
+	var password string
+	for i := range configs {
+		if configs[i].Password != "" {
+			continue
+		}
+		if password == "" {
+			var err error
+			if password, err = prompt.GetPassword(); err != nil {
+				return nil, fmt.Errorf("password prompt failed: %w", err)
+			}
+		}
+		configs[i].Password = password
+		typed[i] = true
+	}
+	return typed, nil
+}
+
+// shareProgress gives every file its own reporter. JSON events name their
+// file; progress bars are dropped when files run at the same time, as they
+// would draw over each other.
+func shareProgress(configs []core.OperationConfig, jobs int) {
+	for i := range configs {
+		switch reporter := configs[i].Progress.(type) {
+		case *progress.JSON:
+			configs[i].Progress = reporter.ForFile(configs[i].InputPath)
+		case *progress.Terminal:
+			if jobs > 1 {
+				configs[i].Progress = progress.Silent{}
+			}
+		}
+	}
+}
+
+// printSummary lists the outcome of every file and returns how many failed.
+func printSummary(w io.Writer, results []core.BatchResult) int {
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+
+	fmt.Fprintf(w, "%d of %d files done, %d failed:\n", len(results)-failed, len(results), failed)
+	for _, result := range results {
+		switch {
+		case result.Err == nil && result.Output == "":
+			fmt.Fprintf(w, "  ok      %s\n", result.Input)
+		case result.Err == nil:
+			fmt.Fprintf(w, "  ok      %s -> %s\n", result.Input, result.Output)
+		case errors.Is(result.Err, context.Canceled):
+			fmt.Fprintf(w, "  skipped %s: interrupted\n", result.Input)
+		default:
+			fmt.Fprintf(w, "  FAILED  %s: %v\n", result.Input, result.Err)
+		}
+	}
+	return failed
+}
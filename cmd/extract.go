@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hambosto/go-encryption/internal/core"
+)
+
+func runExtract(args []string) {
+	flags := flag.NewFlagSet("extract", flag.ExitOnError)
+	offset := flags.Int64("offset", 0, "offset of the range in the original file")
+	length := flags.Int64("length", -1, "length of the range in bytes (required)")
+	output := flags.String("o", "-", "file to write the range to, - for stdout")
+	source := addPasswordFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: go-encryption extract --length N [--offset N] [-o file] [--password-* ...] file.enc\n\n")
+		fmt.Fprintf(flags.Output(), "Decrypts a byte range of an encrypted file, reading only the chunks\n")
+		fmt.Fprintf(flags.Output(), "that hold it.\n\n")
+		flags.PrintDefaults()
+	}
+	files := parseArgs(flags, args)
+	if len(files) != 1 || *length < 0 {
+		flags.Usage()
+		os.Exit(exitUsage)
+	}
+
+	password, err := source.read()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var file *os.File
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		file, err = os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to create output file: %v\n", err)
+			os.Exit(1)
+		}
+		w = file
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	err = core.DecryptRange(ctx, files[0], password, *offset, *length, w)
+	if file != nil {
+		if closeErr := file.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("failed to close output file: %w", closeErr)
+		}
+		if err != nil {
+			os.Remove(*output)
+		}
+	}
+	if err != nil {
+		exitOnError(ctx, err)
+	}
+}
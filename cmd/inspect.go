@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hambosto/go-encryption/internal/core"
+)
+
+func runInspect(args []string) {
+	flags := flag.NewFlagSet("inspect", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the details as JSON")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: go-encryption inspect [--json] file ...\n\n")
+		fmt.Fprintf(flags.Output(), "Prints the header and body layout of encrypted files without the password.\n\n")
+		flags.PrintDefaults()
+	}
+	paths := parseArgs(flags, args)
+	if len(paths) == 0 {
+		flags.Usage()
+		os.Exit(exitUsage)
+	}
+
+	report, err := core.Inspect(paths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *asJSON {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to write report: %v\n", err)
+		os.Exit(1)
+	}
+
+	if report.Failed() {
+		os.Exit(1)
+	}
+}
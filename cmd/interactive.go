@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hambosto/go-encryption/internal/core"
+	"github.com/hambosto/go-encryption/internal/credential"
+	"github.com/hambosto/go-encryption/internal/ui"
+)
+
+// runInteractive walks the user through the operation with prompts. It is
+// only used when no arguments are given and stdin is a terminal.
+func runInteractive() {
+	terminal := ui.NewTerminal()
+	if err := terminal.Clear(); err != nil {
+		fmt.Printf("Error: failed to clear terminal: %v\n", err)
+		os.Exit(1)
+	}
+
+	prompt := ui.NewPrompt()
+
+	operation, err := prompt.GetOperation()
+	if err != nil {
+		fmt.Printf("Error: failed to get operation: %v\n", err)
+		os.Exit(1)
+	}
+
+	fileFinder := ui.NewFileFinder()
+	files, err := fileFinder.FindEligibleFiles(operation)
+	if err != nil {
+		fmt.Printf("Error: failed to list files: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(files) == 0 {
+		fmt.Println("No eligible files found.")
+		os.Exit(1)
+	}
+
+	selectedFiles, err := prompt.SelectFiles(files)
+	if err != nil {
+		fmt.Printf("Error: failed to select files: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	command := os.Getenv(credentialHelperEnv)
+	if len(selectedFiles) > 1 {
+		processor := core.NewProcessor(core.NewFileManager(3), prompt)
+		var passwords *helperPasswords
+		if command != "" && operation != core.Repair {
+			passwords = &helperPasswords{helper: credential.NewHelper(command), prompt: prompt, encrypt: operation == core.Encrypt, typed: make(map[string]bool)}
+			processor.WithPasswords(passwords.lookup)
+		}
+
+		results := processor.ProcessFilesContext(ctx, selectedFiles, operation, 1)
+		fmt.Println()
+		failed := printSummary(os.Stdout, results)
+		if passwords != nil {
+			passwords.store(results)
+		}
+		if failed > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	selectedFile := selectedFiles[0]
+	var questions core.PromptInterface = prompt
+	var helper *credential.Prompt
+	if command != "" {
+		request := credentialRequest(selectedFile, core.DefaultOutputPath(selectedFile, operation), operation == core.Encrypt)
+		helper = credential.NewPrompt(prompt, credential.NewHelper(command), request)
+		questions = helper
+	}
+	processor := core.NewProcessor(core.NewFileManager(3), questions)
+
+	if err := processor.ProcessFileContext(ctx, selectedFile, operation); err != nil {
+		exitOnError(ctx, err)
+	}
+
+	if helper != nil && operation == core.Encrypt {
+		if err := helper.StoreChosen(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+}
+
+// helperPasswords looks up the password of every selected file with the
+// credential helper, as batchPasswords does, and prompts once for the files
+// it has none for.
+type helperPasswords struct {
+	helper   *credential.Helper
+	prompt   core.PromptInterface
+	encrypt  bool
+	typed    map[string]bool
+	asked    bool
+	password string
+	err      error
+}
+
+func (h *helperPasswords) lookup(input, output string) (string, error) {
+	password, err := h.helper.Get(credentialRequest(input, output, h.encrypt))
+	if err != nil || password != "" {
+		return password, err
+	}
+
+	// A failed prompt is not repeated for every remaining file
+	if !h.asked {
+		h.password, h.err = h.prompt.GetPassword()
+		h.asked = true
+	}
+	if h.err != nil {
+		return "", fmt.Errorf("password prompt failed: %w", h.err)
+	}
+	h.typed[input] = true
+	return h.password, nil
+}
+
+// store hands the typed password to the helper for every file encrypted
+// with it.
+func (h *helperPasswords) store(results []core.BatchResult) {
+	if !h.encrypt {
+		return
+	}
+	for _, result := range results {
+		if result.Err != nil || !h.typed[result.Input] {
+			continue
+		}
+		if err := h.helper.Store(credentialRequest(result.Input, result.Output, true), h.password); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hambosto/go-encryption/internal/core"
+)
+
+func runMirror(args []string) {
+	flags := flag.NewFlagSet("mirror", flag.ExitOnError)
+	restore := flags.Bool("restore", false, "decrypt the mirror in source back into a plaintext tree at target")
+	encryptNames := flags.Bool("encrypt-names", false, "hide file and directory names in a new mirror")
+	stripeWidth := flags.Int("stripe", 0, "interleave the shards of this many chunks (0 keeps the contiguous layout)")
+	chunkSize := flags.String("chunk-size", "auto", "plaintext chunk size, e.g. 1M (auto scales with each file)")
+	source := addPasswordFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: go-encryption mirror [flags] source target\n")
+		fmt.Fprintf(flags.Output(), "       go-encryption mirror --restore [flags] mirror target\n\n")
+		fmt.Fprintf(flags.Output(), "Encrypts every file of source individually into the same tree under\n")
+		fmt.Fprintf(flags.Output(), "target. Files unchanged since the last run are skipped, and files gone\n")
+		fmt.Fprintf(flags.Output(), "from source are removed from the mirror.\n\n")
+		flags.PrintDefaults()
+	}
+	dirs := parseArgs(flags, args)
+	if len(dirs) != 2 {
+		flags.Usage()
+		os.Exit(exitUsage)
+	}
+
+	size, err := parseSize(*chunkSize)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: chunk size: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	config := core.MirrorConfig{
+		Source:       dirs[0],
+		Target:       dirs[1],
+		EncryptNames: *encryptNames,
+		StripeWidth:  *stripeWidth,
+		ChunkSize:    size,
+	}
+	if !source.interactive() {
+		if config.Password, err = source.read(); err != nil {
+			exitOnError(ctx, err)
+		}
+	}
+
+	operations := core.NewOperation(core.NewFileManager(3), flagPrompt{password: source})
+	var report core.MirrorReport
+	if *restore {
+		report, err = operations.MirrorRestore(ctx, config)
+	} else {
+		report, err = operations.MirrorEncrypt(ctx, config)
+	}
+
+	for _, path := range report.Skipped {
+		fmt.Printf("Warning: skipped %s, only regular files are mirrored\n", path)
+	}
+	if err == nil || report.Written+report.UpToDate+report.Removed > 0 {
+		fmt.Printf("%d written, %d up to date, %d removed\n", report.Written, report.UpToDate, report.Removed)
+	}
+	if err != nil {
+		exitOnError(ctx, err)
+	}
+}
@@ -0,0 +1,248 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hambosto/go-encryption/internal/core"
+	"github.com/hambosto/go-encryption/internal/credential"
+	"github.com/hambosto/go-encryption/internal/progress"
+)
+
+type operationFlags struct {
+	output           string
+	password         *passwordSource
+	force            bool
+	deletePolicy     string
+	noVerify         bool
+	progress         string
+	checkpoint       bool
+	resume           bool
+	credentialHelper string
+	credentialStore  bool
+	jobs             int
+	reporter         progress.Reporter
+	stripeWidth      int
+	chunkSize        string
+	salvage          string
+}
+
+func runEncrypt(args []string) {
+	flags, opts := newOperationFlags("encrypt")
+	flags.IntVar(&opts.stripeWidth, "stripe", 0, "interleave the shards of this many chunks (0 keeps the contiguous layout)")
+	flags.StringVar(&opts.chunkSize, "chunk-size", "auto", "plaintext chunk size, e.g. 1M (auto scales with the file)")
+	runOperation(flags, opts, args, core.Encrypt)
+}
+
+func runDecrypt(args []string) {
+	flags, opts := newOperationFlags("decrypt")
+	flags.StringVar(&opts.salvage, "salvage", "off", "keep going past unrecoverable chunks: off, zero-fill or skip")
+	runOperation(flags, opts, args, core.Decrypt)
+}
+
+func runRepair(args []string) {
+	flags := flag.NewFlagSet("repair", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: go-encryption repair file.enc\n\n")
+		fmt.Fprintf(flags.Output(), "Rebuilds damaged shards from parity and replaces the file with the healed\n")
+		fmt.Fprintf(flags.Output(), "copy. No password is needed.\n")
+	}
+	runInPlace(flags, args, core.OperationRepair)
+}
+
+func runMigrate(args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: go-encryption migrate file.enc\n\n")
+		fmt.Fprintf(flags.Output(), "Converts a file written in format version 1, before headers carried a\n")
+		fmt.Fprintf(flags.Output(), "version, to the current format and replaces it. No password is needed.\n")
+	}
+	runInPlace(flags, args, core.OperationMigrate)
+}
+
+// runInPlace runs an operation that rewrites a single file without the
+// password.
+func runInPlace(flags *flag.FlagSet, args []string, op core.OperationType) {
+	files := parseArgs(flags, args)
+	if len(files) != 1 {
+		flags.Usage()
+		os.Exit(exitUsage)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	operations := core.NewOperation(core.NewFileManager(3), flagPrompt{})
+	config := core.OperationConfig{
+		InputPath:  files[0],
+		OutputPath: files[0],
+		Operation:  op,
+	}
+	if err := operations.ProcessContext(ctx, config); err != nil {
+		exitOnError(ctx, err)
+	}
+}
+
+func runVerify(args []string) {
+	opts := &operationFlags{deletePolicy: "keep"}
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	opts.password = addPasswordFlags(flags)
+	flags.StringVar(&opts.progress, "progress", defaultProgress(), "progress output: bar, none, json or json:FD")
+	flags.IntVar(&opts.jobs, "jobs", 1, "with several files, how many to verify at once; the CPUs are split between them")
+	flags.StringVar(&opts.credentialHelper, "credential-helper", os.Getenv(credentialHelperEnv), "command to ask for the password (also taken from $"+credentialHelperEnv+")")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: go-encryption verify [flags] file.enc...\n\n")
+		fmt.Fprintf(flags.Output(), "Decrypts every chunk with the password and discards the result, reporting\n")
+		fmt.Fprintf(flags.Output(), "the SHA-256 of the plaintext or the first chunk that fails. Nothing is written.\n\n")
+		flags.PrintDefaults()
+	}
+	runOperation(flags, opts, args, core.Verify)
+}
+
+func newOperationFlags(name string) (*flag.FlagSet, *operationFlags) {
+	opts := &operationFlags{}
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+
+	flags.StringVar(&opts.output, "o", "", "output path, - for stdout (default: the input with .enc added or removed)")
+	opts.password = addPasswordFlags(flags)
+	flags.BoolVar(&opts.force, "force", false, "overwrite the output if it exists")
+	flags.StringVar(&opts.deletePolicy, "delete", "keep", "what to do with the input afterwards: keep, delete or secure")
+	flags.BoolVar(&opts.noVerify, "no-verify", false, "delete the original after encrypting without decrypting the new file again to check it")
+	flags.StringVar(&opts.progress, "progress", defaultProgress(), "progress output: bar, none, json or json:FD")
+	flags.BoolVar(&opts.checkpoint, "checkpoint", false, "record progress so an interrupted run can be resumed")
+	flags.BoolVar(&opts.resume, "resume", false, "continue an interrupted run from its checkpoint")
+	flags.IntVar(&opts.jobs, "jobs", 1, "with several files, how many to process at once; the CPUs are split between them")
+	flags.StringVar(&opts.credentialHelper, "credential-helper", os.Getenv(credentialHelperEnv), "command to ask for the password (also taken from $"+credentialHelperEnv+")")
+	flags.BoolVar(&opts.credentialStore, "credential-store", false, "hand a newly typed password to the credential helper after encrypting")
+
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: go-encryption %s [flags] file...\n\n", name)
+		fmt.Fprintf(flags.Output(), "Use - as the file to read stdin; the output then defaults to stdout.\n\n")
+		flags.PrintDefaults()
+	}
+	return flags, opts
+}
+
+func defaultProgress() string {
+	if isTerminal(os.Stderr) {
+		return "bar"
+	}
+	return "none"
+}
+
+func runOperation(flags *flag.FlagSet, opts *operationFlags, args []string, op core.OperationType) {
+	files := parseArgs(flags, args)
+	if len(files) == 0 {
+		flags.Usage()
+		os.Exit(exitUsage)
+	}
+	if len(files) > 1 {
+		runBatch(opts, files, op)
+		return
+	}
+
+	config, err := opts.config(files[0], op)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	piped := config.InputPath == "-" || config.OutputPath == "-"
+	if piped {
+		if err := checkPipe(opts, config); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitUsage)
+		}
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	if !opts.password.interactive() {
+		if config.Password, err = opts.password.read(); err != nil {
+			exitOnError(ctx, err)
+		}
+	}
+
+	var prompt core.PromptInterface = flagPrompt{password: opts.password, force: opts.force, deletePolicy: opts.deletePolicy}
+	var helper *credential.Prompt
+	if opts.credentialHelper != "" && config.Password == "" {
+		helper = credential.NewPrompt(prompt, credential.NewHelper(opts.credentialHelper), credentialRequest(config.InputPath, config.OutputPath, config.Operation == core.OperationEncrypt))
+		prompt = helper
+	}
+
+	if piped {
+		err = runPipe(ctx, config, prompt, opts.force)
+	} else {
+		err = core.NewOperation(core.NewFileManager(3), prompt).ProcessContext(ctx, config)
+	}
+	if err != nil {
+		exitOnError(ctx, err)
+	}
+
+	if helper != nil && opts.credentialStore && config.Operation == core.OperationEncrypt {
+		if err := helper.StoreChosen(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+}
+
+func credentialRequest(input, output string, encrypt bool) credential.Request {
+	operation := "decrypt"
+	if encrypt {
+		operation = "encrypt"
+	}
+	return credential.Request{Operation: operation, Path: input, Output: output}
+}
+
+func (o *operationFlags) config(input string, op core.OperationType) (core.OperationConfig, error) {
+	config := core.OperationConfig{
+		InputPath:   input,
+		OutputPath:  o.output,
+		StripeWidth: o.stripeWidth,
+		Checkpoint:  o.checkpoint,
+		Resume:      o.resume,
+		SkipVerify:  o.noVerify,
+	}
+	if op == core.Verify && input == "-" {
+		return config, fmt.Errorf("verify needs a file, not stdin")
+	}
+	if config.OutputPath == "" && op != core.Verify {
+		config.OutputPath = "-"
+		if input != "-" {
+			config.OutputPath = core.DefaultOutputPath(input, op)
+		}
+	}
+
+	switch op {
+	case core.Decrypt:
+		config.Operation = core.OperationDecrypt
+	case core.Verify:
+		config.Operation = core.OperationVerify
+	default:
+		config.Operation = core.OperationEncrypt
+	}
+
+	var err error
+	if o.deletePolicy, err = parseDeletePolicy(o.deletePolicy); err != nil {
+		return config, err
+	}
+	if config.ChunkSize, err = parseSize(o.chunkSize); err != nil {
+		return config, fmt.Errorf("chunk size: %w", err)
+	}
+	if o.salvage != "" {
+		if config.Salvage, err = parseSalvage(o.salvage); err != nil {
+			return config, err
+		}
+	}
+	// Parsed once, as json:FD wraps the descriptor in a file that closes it
+	// when collected
+	if o.reporter == nil {
+		if o.reporter, err = progress.Parse(o.progress); err != nil {
+			return config, err
+		}
+	}
+	config.Progress = o.reporter
+	return config, nil
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/hambosto/go-encryption/internal/ui"
+)
+
+// credentialHelperEnv names the variable holding the default credential
+// helper command.
+const credentialHelperEnv = "GO_ENCRYPTION_CREDENTIAL_HELPER"
+
+// passwordSource is where the password comes from when it is not typed at a
+// prompt. At most one source may be chosen.
+type passwordSource struct {
+	stdin bool
+	env   string
+	file  string
+	fd    int
+}
+
+func addPasswordFlags(flags *flag.FlagSet) *passwordSource {
+	source := &passwordSource{}
+	flags.BoolVar(&source.stdin, "password-stdin", false, "read the password from the first line of stdin")
+	flags.StringVar(&source.env, "password-env", "", "read the password from this environment variable")
+	flags.StringVar(&source.file, "password-file", "", "read the password from the first line of this file")
+	flags.IntVar(&source.fd, "password-fd", -1, "read the password from the first line of this inherited file descriptor")
+	return source
+}
+
+// interactive reports whether no source was chosen and the password has to
+// be prompted for.
+func (s *passwordSource) interactive() bool {
+	return !s.stdin && s.env == "" && s.file == "" && s.fd < 0
+}
+
+func (s *passwordSource) read() (string, error) {
+	chosen := 0
+	for _, set := range []bool{s.stdin, s.env != "", s.file != "", s.fd >= 0} {
+		if set {
+			chosen++
+		}
+	}
+	if chosen > 1 {
+		return "", errors.New("choose only one of --password-stdin, --password-env, --password-file and --password-fd")
+	}
+
+	switch {
+	case s.stdin:
+		return readPasswordLine(os.Stdin, "stdin")
+	case s.env != "":
+		return s.readEnv()
+	case s.file != "":
+		return s.readFile()
+	case s.fd >= 0:
+		file := os.NewFile(uintptr(s.fd), fmt.Sprintf("fd %d", s.fd))
+		if file == nil {
+			return "", fmt.Errorf("invalid password file descriptor %d", s.fd)
+		}
+		defer file.Close()
+		return readPasswordLine(file, file.Name())
+	default:
+		return promptPassword()
+	}
+}
+
+func (s *passwordSource) readEnv() (string, error) {
+	password, ok := os.LookupEnv(s.env)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", s.env)
+	}
+	if password == "" {
+		return "", fmt.Errorf("environment variable %s is empty", s.env)
+	}
+
+	// Keep it from being inherited by anything started later
+	_ = os.Unsetenv(s.env)
+	fmt.Fprintf(os.Stderr, "Warning: the environment of a process can be read by other processes of the same user; prefer --password-file or --password-fd\n")
+	return password, nil
+}
+
+func (s *passwordSource) readFile() (string, error) {
+	file, err := os.Open(s.file)
+	if err != nil {
+		return "", fmt.Errorf("failed to open password file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat password file: %w", err)
+	}
+	// Windows reports no meaningful permission bits
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		fmt.Fprintf(os.Stderr, "Warning: password file %s is accessible by other users (mode %04o); restrict it with chmod 600\n", s.file, info.Mode().Perm())
+	}
+	return readPasswordLine(file, s.file)
+}
+
+func readPasswordLine(r io.Reader, name string) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read password from %s: %w", name, err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", fmt.Errorf("password read from %s is empty", name)
+	}
+	return password, nil
+}
+
+// promptPassword asks on the terminal, which need not be stdin.
+func promptPassword() (string, error) {
+	if isTerminal(os.Stdin) {
+		return ui.NewPrompt().GetPassword()
+	}
+
+	// stdin carries data, so ask on the terminal itself if there is one
+	prompt, err := ui.NewTTYPrompt()
+	if err != nil {
+		return "", errors.New("no password given and no terminal to prompt on, use one of the --password-* flags")
+	}
+	return prompt.GetPassword()
+}
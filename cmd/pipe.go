@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hambosto/go-encryption/internal/core"
+	"github.com/hambosto/go-encryption/internal/worker"
+)
+
+// runPipe handles an operation where the input or output is "-". Data is
+// processed strictly in order, so nothing that needs to seek or revisit the
+// files is available; checkPipe rejects the flags asking for that.
+func runPipe(ctx context.Context, config core.OperationConfig, prompt core.PromptInterface, force bool) error {
+	if config.Password == "" {
+		password, err := prompt.GetPassword()
+		if err != nil {
+			return err
+		}
+		config.Password = password
+	}
+
+	input := io.Reader(os.Stdin)
+	if config.InputPath != "-" {
+		file, err := os.Open(config.InputPath)
+		if err != nil {
+			return fmt.Errorf("failed to open input: %w", err)
+		}
+		defer file.Close()
+		if info, err := file.Stat(); err == nil && info.IsDir() {
+			return errors.New("directories cannot be written to stdout")
+		}
+		input = file
+	}
+
+	output, err := openPipeOutput(config.OutputPath, force)
+	if err != nil {
+		return err
+	}
+
+	if config.Operation == core.OperationEncrypt {
+		err = core.EncryptStream(ctx, input, output, config)
+	} else {
+		err = core.DecryptStream(ctx, input, output, config)
+	}
+	if closeErr := output.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close output: %w", closeErr)
+	}
+
+	if err != nil && output != os.Stdout {
+		_ = os.Remove(config.OutputPath)
+	}
+	return err
+}
+
+func checkPipe(opts *operationFlags, config core.OperationConfig) error {
+	switch {
+	case opts.deletePolicy != "keep":
+		return errors.New("--delete cannot be used with stdin or stdout")
+	case config.Checkpoint || config.Resume:
+		return errors.New("--checkpoint and --resume cannot be used with stdin or stdout")
+	case config.Salvage != worker.SalvageOff:
+		return errors.New("--salvage cannot be used with stdin or stdout")
+	case opts.password.stdin && config.InputPath == "-":
+		return errors.New("--password-stdin cannot be used when stdin carries the input")
+	case config.Operation == core.OperationEncrypt && config.OutputPath == "-" && isTerminal(os.Stdout):
+		return errors.New("refusing to write encrypted data to a terminal, use -o or redirect stdout")
+	}
+	return nil
+}
+
+func openPipeOutput(path string, force bool) (*os.File, error) {
+	if path == "-" {
+		return os.Stdout, nil
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	file, err := os.OpenFile(path, flags, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return nil, fmt.Errorf("output file %s already exists, use --force to overwrite it", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output: %w", err)
+	}
+	return file, nil
+}
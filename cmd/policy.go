@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hambosto/go-encryption/internal/core"
+	"github.com/hambosto/go-encryption/internal/worker"
+)
+
+// flagPrompt answers the questions of core from command-line flags, so
+// that nothing blocks on a prompt when running from a script.
+type flagPrompt struct {
+	password     *passwordSource
+	force        bool
+	deletePolicy string
+}
+
+func (p flagPrompt) ConfirmOverwrite(path string) (bool, error) {
+	if !p.force {
+		return false, fmt.Errorf("%s already exists, use --force to overwrite it", path)
+	}
+	return true, nil
+}
+
+func (p flagPrompt) ConfirmResume(string) (bool, error) {
+	return false, nil
+}
+
+// ConfirmCheckpoint is not asked on the command line, where --checkpoint
+// sets it directly.
+func (p flagPrompt) ConfirmCheckpoint() (bool, error) {
+	return false, nil
+}
+
+func (p flagPrompt) GetPassword() (string, error) {
+	return p.password.read()
+}
+
+func (p flagPrompt) ConfirmDelete(string, string) (bool, core.DeleteType, error) {
+	switch p.deletePolicy {
+	case "delete":
+		return true, core.DeleteTypeNormal, nil
+	case "secure":
+		return true, core.DeleteTypeSecure, nil
+	default:
+		return false, "", nil
+	}
+}
+
+func (p flagPrompt) GetOperation() (core.OperationType, error) {
+	return "", errors.New("no operation selected")
+}
+
+func (p flagPrompt) SelectFile([]string) (string, error) {
+	return "", errors.New("no file selected")
+}
+
+func (p flagPrompt) SelectFiles([]string) ([]string, error) {
+	return nil, errors.New("no files selected")
+}
+
+func parseDeletePolicy(policy string) (string, error) {
+	switch policy {
+	case "keep", "delete", "secure":
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid delete policy %q, want keep, delete or secure", policy)
+	}
+}
+
+func parseSalvage(mode string) (worker.SalvageMode, error) {
+	for _, m := range []worker.SalvageMode{worker.SalvageOff, worker.SalvageZeroFill, worker.SalvageSkip} {
+		if m.String() == mode {
+			return m, nil
+		}
+	}
+	return worker.SalvageOff, fmt.Errorf("invalid salvage mode %q, want off, zero-fill or skip", mode)
+}
+
+// parseSize accepts a byte count with an optional K or M suffix, in
+// binary units.
+func parseSize(value string) (int, error) {
+	if value == "" || value == "auto" {
+		return 0, nil
+	}
+
+	multiplier := 1
+	switch strings.ToUpper(value[len(value)-1:]) {
+	case "K":
+		multiplier = 1024
+	case "M":
+		multiplier = 1024 * 1024
+	}
+	if multiplier > 1 {
+		value = value[:len(value)-1]
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return n * multiplier, nil
+}
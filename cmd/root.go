@@ -1,50 +1,92 @@
 package cmd
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"slices"
 
-	"github.com/hambosto/go-encryption/internal/core"
-	"github.com/hambosto/go-encryption/internal/ui"
+	"golang.org/x/term"
 )
 
+// exitUsage is the status for malformed command lines, as used by flag.
+const exitUsage = 2
+
 func Execute() {
-	terminal := ui.NewTerminal()
-	if err := terminal.Clear(); err != nil {
-		fmt.Printf("Error: failed to clear terminal: %v\n", err)
-		os.Exit(1)
+	args := os.Args[1:]
+	if len(args) == 0 {
+		if !isTerminal(os.Stdin) {
+			printUsage(os.Stderr)
+			os.Exit(exitUsage)
+		}
+		runInteractive()
+		return
 	}
 
-	prompt := ui.NewPrompt()
-	fileManager := core.NewFileManager(3)
-	processor := core.NewProcessor(fileManager, prompt)
-
-	operation, err := prompt.GetOperation()
-	if err != nil {
-		fmt.Printf("Error: failed to get operation: %v\n", err)
-		os.Exit(1)
+	switch args[0] {
+	case "encrypt":
+		runEncrypt(args[1:])
+	case "decrypt":
+		runDecrypt(args[1:])
+	case "repair":
+		runRepair(args[1:])
+	case "verify":
+		runVerify(args[1:])
+	case "migrate":
+		runMigrate(args[1:])
+	case "scrub":
+		runScrub(args[1:])
+	case "inspect":
+		runInspect(args[1:])
+	case "extract":
+		runExtract(args[1:])
+	case "mirror":
+		runMirror(args[1:])
+	case "help", "-h", "-help", "--help":
+		printUsage(os.Stdout)
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown command %q\n\n", args[0])
+		printUsage(os.Stderr)
+		os.Exit(exitUsage)
 	}
+}
 
-	fileFinder := ui.NewFileFinder()
-	files, err := fileFinder.FindEligibleFiles(operation)
-	if err != nil {
-		fmt.Printf("Error: failed to list files: %v\n", err)
-		os.Exit(1)
-	}
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: go-encryption [command] [flags] [arguments]\n\n")
+	fmt.Fprintf(w, "Without a command the interactive mode is started.\n\n")
+	fmt.Fprintf(w, "Commands:\n")
+	fmt.Fprintf(w, "  encrypt   encrypt a file\n")
+	fmt.Fprintf(w, "  decrypt   decrypt a file\n")
+	fmt.Fprintf(w, "  repair    rebuild damaged parts of an encrypted file\n")
+	fmt.Fprintf(w, "  verify    check that encrypted files decrypt, writing nothing\n")
+	fmt.Fprintf(w, "  migrate   convert a file from format version 1 to the current format\n")
+	fmt.Fprintf(w, "  scrub     check encrypted files for damage\n")
+	fmt.Fprintf(w, "  inspect   show the header and layout of an encrypted file\n")
+	fmt.Fprintf(w, "  extract   decrypt a byte range of an encrypted file\n")
+	fmt.Fprintf(w, "  mirror    encrypt a directory file by file into a mirror tree\n\n")
+	fmt.Fprintf(w, "Run 'go-encryption <command> -h' for the flags of a command.\n")
+}
 
-	if len(files) == 0 {
-		fmt.Println("No eligible files found.")
-		os.Exit(1)
+// parseArgs parses flags given before, between or after the positional
+// arguments and returns the latter. Everything after "--" is positional.
+func parseArgs(flags *flag.FlagSet, args []string) []string {
+	var rest []string
+	if i := slices.Index(args, "--"); i >= 0 {
+		args, rest = args[:i], args[i+1:]
 	}
 
-	selectedFile, err := prompt.SelectFile(files)
-	if err != nil {
-		fmt.Printf("Error: failed to select file: %v\n", err)
-		os.Exit(1)
+	var positional []string
+	for {
+		flags.Parse(args)
+		if flags.NArg() == 0 {
+			return append(positional, rest...)
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
 	}
+}
 
-	if err := processor.ProcessFile(selectedFile, operation); err != nil {
-		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
-	}
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
 }
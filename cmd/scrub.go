@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hambosto/go-encryption/internal/core"
+)
+
+// exitUnhealthy is distinct from exitUsage, so that a script can tell a
+// damaged file from a mistyped command line.
+const exitUnhealthy = 3
+
+func runScrub(args []string) {
+	flags := flag.NewFlagSet("scrub", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: go-encryption scrub [--json] [path ...]\n\n")
+		fmt.Fprintf(flags.Output(), "Checks encrypted files for damage without the password. Directories are\n")
+		fmt.Fprintf(flags.Output(), "searched recursively for .enc files; the default path is the current directory.\n")
+		fmt.Fprintf(flags.Output(), "The exit status is %d if any file is not healthy, %d for usage errors.\n\n", exitUnhealthy, exitUsage)
+		flags.PrintDefaults()
+	}
+	paths := parseArgs(flags, args)
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	report, err := core.Scrub(paths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *asJSON {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to write report: %v\n", err)
+		os.Exit(1)
+	}
+
+	if !report.Healthy() {
+		os.Exit(exitUnhealthy)
+	}
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/hambosto/go-encryption/internal/worker"
+)
+
+// exitInterrupted follows the shell convention of 128 + SIGINT.
+const exitInterrupted = 130
+
+// interruptContext returns a context that is cancelled on the first SIGINT
+// or SIGTERM. The handler is removed at that point, so a second signal
+// terminates the process immediately.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
+
+// exitOnError prints err and exits, using a distinct status when the
+// operation was interrupted.
+func exitOnError(ctx context.Context, err error) {
+	if ctx.Err() != nil && errors.Is(err, context.Canceled) {
+		var cancelled *worker.CancelledError
+		if errors.As(err, &cancelled) {
+			fmt.Fprintf(os.Stderr, "\nInterrupted after %d bytes: ", cancelled.BytesProcessed)
+		} else {
+			fmt.Fprint(os.Stderr, "\nInterrupted: ")
+		}
+		fmt.Fprintln(os.Stderr, "original file left untouched")
+		os.Exit(exitInterrupted)
+	}
+
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,192 @@
+// Package archive packs a directory tree into a single tar stream, and
+// unpacks such a stream into a new directory without letting any entry
+// escape it.
+package archive
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Magic precedes the tar stream, so that decryption can tell a directory
+// from an ordinary file that happens to be a tar archive.
+var Magic = []byte("GENCDIR\x01")
+
+// endSize is the two zero blocks that close a tar stream.
+const endSize = 2 * 512
+
+type entry struct {
+	header *tar.Header
+	path   string
+}
+
+// Archive is the planned content of a directory: directories, regular
+// files and symbolic links, with their permissions and modification times.
+// Its size is known before anything is written, since the encrypted header
+// records it upfront.
+type Archive struct {
+	entries []entry
+	size    int64
+	skipped []string
+}
+
+// Plan walks root and records what WriteTo will write. Entries of other
+// types, such as sockets and devices, are left out and listed by Skipped.
+func Plan(root string) (*Archive, error) {
+	a := &Archive{size: int64(len(Magic)) + endSize}
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		hdr, err := newHeader(filepath.ToSlash(rel), path, info)
+		if err != nil {
+			return err
+		}
+		if hdr == nil {
+			a.skipped = append(a.skipped, path)
+			return nil
+		}
+
+		size, err := headerSize(hdr)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		a.size += size + blockAlign(hdr.Size)
+		a.entries = append(a.entries, entry{header: hdr, path: path})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %w", root, err)
+	}
+
+	return a, nil
+}
+
+func newHeader(name string, path string, info fs.FileInfo) (*tar.Header, error) {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    int64(info.Mode().Perm()),
+		ModTime: info.ModTime(),
+		Format:  tar.FormatPAX,
+	}
+
+	switch mode := info.Mode(); {
+	case mode.IsDir():
+		hdr.Typeflag = tar.TypeDir
+		hdr.Name += "/"
+	case mode.IsRegular():
+		hdr.Typeflag = tar.TypeReg
+		hdr.Size = info.Size()
+	case mode&fs.ModeSymlink != 0:
+		target, err := os.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+		hdr.Typeflag = tar.TypeSymlink
+		hdr.Linkname = target
+	default:
+		return nil, nil
+	}
+	return hdr, nil
+}
+
+// headerSize returns the encoded length of hdr, including any PAX records.
+func headerSize(hdr *tar.Header) (int64, error) {
+	var counter countingWriter
+	if err := tar.NewWriter(&counter).WriteHeader(hdr); err != nil {
+		return 0, err
+	}
+	return counter.n, nil
+}
+
+func blockAlign(size int64) int64 {
+	return (size + 511) &^ 511
+}
+
+// Size is the exact number of bytes WriteTo writes.
+func (a *Archive) Size() int64 {
+	return a.size
+}
+
+func (a *Archive) Skipped() []string {
+	return a.skipped
+}
+
+// WriteTo writes the archive. Regular files are written with the size they
+// had when planned; one that has shrunk since fails the archive, one that
+// has grown is cut at that size.
+func (a *Archive) WriteTo(w io.Writer) (int64, error) {
+	counter := &countingWriter{w: w}
+	if _, err := counter.Write(Magic); err != nil {
+		return counter.n, err
+	}
+
+	tw := tar.NewWriter(counter)
+	for _, e := range a.entries {
+		if err := tw.WriteHeader(e.header); err != nil {
+			return counter.n, fmt.Errorf("%s: %w", e.path, err)
+		}
+		if e.header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := copyFile(tw, e.path, e.header.Size); err != nil {
+			return counter.n, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return counter.n, err
+	}
+
+	if counter.n != a.size {
+		return counter.n, fmt.Errorf("archive is %d bytes, planned %d", counter.n, a.size)
+	}
+	return counter.n, nil
+}
+
+func copyFile(w io.Writer, path string, size int64) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := io.CopyN(w, file, size); err != nil {
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("%s shrank while being archived", path)
+		}
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.w == nil {
+		c.n += int64(len(p))
+		return len(p), nil
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
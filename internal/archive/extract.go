@@ -0,0 +1,163 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+type directory struct {
+	path    string
+	mode    os.FileMode
+	modTime time.Time
+}
+
+// Extract unpacks an archive written by WriteTo into dest, which must be an
+// existing, empty directory.
+//
+// Entries with absolute names or names leading out of dest are rejected.
+// Files are never written through a symbolic link: links are only created
+// once every file is in place, and files are created exclusively, so an
+// entry cannot replace or reach through anything made before it.
+func Extract(r io.Reader, dest string) error {
+	magic := make([]byte, len(Magic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return fmt.Errorf("failed to read archive marker: %w", err)
+	}
+	if !bytes.Equal(magic, Magic) {
+		return errors.New("not a directory archive")
+	}
+
+	var directories []directory
+	var links []*tar.Header
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		name, err := entryName(hdr.Name)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, filepath.FromSlash(name))
+		mode := os.FileMode(hdr.Mode).Perm()
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			// Kept writable until the end, then given its own mode
+			if err := os.MkdirAll(target, 0o700); err != nil {
+				return err
+			}
+			directories = append(directories, directory{path: target, mode: mode, modTime: hdr.ModTime})
+		case tar.TypeReg:
+			if err := extractFile(tr, target, mode, hdr.ModTime); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if name == "." {
+				return errors.New("archive root cannot be a symbolic link")
+			}
+			links = append(links, hdr)
+		default:
+			return fmt.Errorf("unsupported entry %s of type %q", hdr.Name, hdr.Typeflag)
+		}
+	}
+
+	for _, link := range links {
+		name, _ := entryName(link.Name)
+		target := filepath.Join(dest, filepath.FromSlash(name))
+		// An earlier link may sit where this one's parent should be
+		if err := checkNoLinks(dest, name); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+			return err
+		}
+		if err := os.Symlink(link.Linkname, target); err != nil {
+			return err
+		}
+	}
+
+	// Deepest first, so that restoring a directory's time is not undone by
+	// changes inside it
+	slices.Reverse(directories)
+	for _, dir := range directories {
+		if err := os.Chmod(dir.path, dir.mode); err != nil {
+			return err
+		}
+		if err := os.Chtimes(dir.path, dir.modTime, dir.modTime); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// entryName cleans the name of an entry, rejecting any that is absolute or
+// leads outside the archive root.
+func entryName(name string) (string, error) {
+	if name == "" || strings.HasPrefix(name, "/") || strings.Contains(name, `\`) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("unsafe entry name %q", name)
+	}
+	clean := path.Clean(name)
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("unsafe entry name %q", name)
+	}
+	return clean, nil
+}
+
+// checkNoLinks fails if any existing parent of name below dest is a
+// symbolic link.
+func checkNoLinks(dest string, name string) error {
+	parent := dest
+	for _, part := range strings.Split(path.Dir(name), "/") {
+		if part == "." {
+			continue
+		}
+		parent = filepath.Join(parent, part)
+		info, err := os.Lstat(parent)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("entry %s lies beneath a symbolic link", name)
+		}
+	}
+	return nil
+}
+
+func extractFile(r io.Reader, target string, mode os.FileMode, modTime time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(target, modTime, modTime)
+}
@@ -8,7 +8,7 @@ import (
 )
 
 type AESCipher struct {
-	key   []byte
+	aead  cipher.AEAD
 	nonce []byte
 }
 
@@ -23,52 +23,52 @@ func NewAESCipher(key []byte) (*AESCipher, error) {
 		return nil, fmt.Errorf("AES key must be 16, 24, or 32 bytes")
 	}
 
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AES GCM: %w", err)
+	}
+
 	nonce := make([]byte, 12)
 	if _, err := rand.Read(nonce); err != nil {
 		return nil, fmt.Errorf("failed to generate nonce: %w", err)
 	}
 
 	return &AESCipher{
-		key:   key,
+		aead:  aead,
 		nonce: nonce,
 	}, nil
 }
 
 func (c *AESCipher) Encrypt(plaintext []byte) ([]byte, error) {
-	if len(plaintext) == 0 {
-		return nil, fmt.Errorf("plaintext cannot be empty")
-	}
+	return c.Seal(nil, plaintext)
+}
 
-	block, err := aes.NewCipher(c.key)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
-	}
+func (c *AESCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	return c.Open(nil, ciphertext)
+}
 
-	aead, err := cipher.NewGCM(block)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create AES GCM: %w", err)
+// Seal appends the encryption of plaintext to dst. The AEAD is shared and
+// safe for concurrent use.
+func (c *AESCipher) Seal(dst, plaintext []byte) ([]byte, error) {
+	if len(plaintext) == 0 {
+		return nil, fmt.Errorf("plaintext cannot be empty")
 	}
 
-	ciphertext := aead.Seal(nil, c.nonce, plaintext, nil)
-	return ciphertext, nil
+	return c.aead.Seal(dst, c.nonce, plaintext, nil), nil
 }
 
-func (c *AESCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+// Open appends the decryption of ciphertext to dst.
+func (c *AESCipher) Open(dst, ciphertext []byte) ([]byte, error) {
 	if len(ciphertext) == 0 {
 		return nil, fmt.Errorf("ciphertext cannot be empty")
 	}
 
-	block, err := aes.NewCipher(c.key)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
-	}
-
-	aead, err := cipher.NewGCM(block)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create AES GCM: %w", err)
-	}
-
-	plaintext, err := aead.Open(nil, c.nonce, ciphertext, nil)
+	plaintext, err := c.aead.Open(dst, c.nonce, ciphertext, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decrypt ciphertext: %w", err)
 	}
@@ -1,6 +1,7 @@
 package cipher
 
 import (
+	"crypto/cipher"
 	"crypto/rand"
 	"fmt"
 
@@ -8,7 +9,7 @@ import (
 )
 
 type ChaCha20Cipher struct {
-	key   []byte
+	aead  cipher.AEAD
 	nonce []byte
 }
 
@@ -17,44 +18,50 @@ func NewChaCha20Cipher(key []byte) (*ChaCha20Cipher, error) {
 		return nil, fmt.Errorf("invalid key size: %d bytes, expected %d bytes", len(key), chacha20poly1305.KeySize)
 	}
 
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ChaCha20 cipher: %w", err)
+	}
+
 	nonce := make([]byte, 24)
 	if _, err := rand.Read(nonce); err != nil {
 		return nil, fmt.Errorf("failed to generate nonce: %w", err)
 	}
 
 	return &ChaCha20Cipher{
-		key:   key,
+		aead:  aead,
 		nonce: nonce,
 	}, nil
 }
 
 func (c *ChaCha20Cipher) Encrypt(plaintext []byte) ([]byte, error) {
+	return c.Seal(nil, plaintext)
+}
+
+func (c *ChaCha20Cipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	return c.Open(nil, ciphertext)
+}
+
+// Seal appends the encryption of plaintext to dst. The AEAD is shared and
+// safe for concurrent use.
+func (c *ChaCha20Cipher) Seal(dst, plaintext []byte) ([]byte, error) {
 	if len(plaintext) == 0 {
 		return nil, fmt.Errorf("plaintext cannot be empty")
 	}
 
-	aead, err := chacha20poly1305.New(c.key)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create ChaCha20 cipher: %w", err)
-	}
-
 	nonce := c.nonce[:chacha20poly1305.NonceSize]
-	return aead.Seal(nil, nonce, plaintext, nil), nil
+	return c.aead.Seal(dst, nonce, plaintext, nil), nil
 }
 
-func (c *ChaCha20Cipher) Decrypt(ciphertext []byte) ([]byte, error) {
+// Open appends the decryption of ciphertext to dst.
+func (c *ChaCha20Cipher) Open(dst, ciphertext []byte) ([]byte, error) {
 	if len(ciphertext) < chacha20poly1305.Overhead {
 		return nil, fmt.Errorf("ciphertext must be at least %d bytes long", chacha20poly1305.Overhead)
 	}
 
-	aead, err := chacha20poly1305.New(c.key)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create ChaCha20 cipher: %w", err)
-	}
-
 	nonce := c.nonce[:chacha20poly1305.NonceSize]
 
-	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	plaintext, err := c.aead.Open(dst, nonce, ciphertext, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decrypt ciphertext: %w", err)
 	}
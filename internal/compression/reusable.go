@@ -0,0 +1,90 @@
+package compression
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Compressor holds a zlib writer that is reset for every call instead of
+// being allocated again. It is not safe for concurrent use.
+type Compressor struct {
+	writer *zlib.Writer
+}
+
+func NewCompressor() (*Compressor, error) {
+	w, err := zlib.NewWriterLevel(io.Discard, zlib.BestSpeed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zlib writer: %w", err)
+	}
+	return &Compressor{writer: w}, nil
+}
+
+// CompressTo appends the compressed form of data to dst.
+func (c *Compressor) CompressTo(dst *bytes.Buffer, data []byte) error {
+	c.writer.Reset(dst)
+
+	if _, err := c.writer.Write(data); err != nil {
+		return fmt.Errorf("failed to write data to zlib compressor: %w", err)
+	}
+
+	if err := c.writer.Close(); err != nil {
+		return fmt.Errorf("failed to close zlib writer: %w", err)
+	}
+
+	return nil
+}
+
+// Decompressor holds a zlib reader that is reset for every call. It is not
+// safe for concurrent use.
+type Decompressor struct {
+	reader io.ReadCloser
+	source bytes.Reader
+}
+
+func NewDecompressor() *Decompressor {
+	return &Decompressor{}
+}
+
+// DecompressTo reads the size-prefixed payload produced by the encryption
+// path, as DecompressData does, and appends the decompressed bytes to dst.
+func (d *Decompressor) DecompressTo(dst *bytes.Buffer, data []byte) error {
+	if len(data) < 4 {
+		return fmt.Errorf("invalid data: insufficient bytes for size header")
+	}
+
+	compressedSize := binary.BigEndian.Uint32(data[:4])
+	if compressedSize > uint32(len(data)-4) {
+		return fmt.Errorf("invalid compressed data size: expected %d, got %d bytes available",
+			compressedSize, len(data)-4)
+	}
+
+	d.source.Reset(data[4 : 4+compressedSize])
+	if err := d.reset(); err != nil {
+		return err
+	}
+
+	if _, err := dst.ReadFrom(d.reader); err != nil {
+		return fmt.Errorf("failed to decompress data with zlib: %w", err)
+	}
+
+	return nil
+}
+
+func (d *Decompressor) reset() error {
+	if d.reader == nil {
+		r, err := zlib.NewReader(&d.source)
+		if err != nil {
+			return fmt.Errorf("failed to create zlib reader: %w", err)
+		}
+		d.reader = r
+		return nil
+	}
+
+	if err := d.reader.(zlib.Resetter).Reset(&d.source, nil); err != nil {
+		return fmt.Errorf("failed to reset zlib reader: %w", err)
+	}
+	return nil
+}
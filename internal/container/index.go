@@ -0,0 +1,144 @@
+package container
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	offsetSize  = 8
+	locatorSize = offsetSize + 4
+)
+
+// indexTag ends the locator that points at the index record, so a file
+// without an index is recognised rather than misread.
+var indexTag = []byte("GIDX")
+
+// The chunk index follows the end-of-body marker:
+//
+//	length | index record | offset of the index record | "GIDX"
+//
+// The index lists the body offset of every record, so the record holding
+// chunk i is entry i / max(stripeWidth, 1), followed by the plaintext size
+// of the file:
+//
+//	count | offset[0..count) | size
+//
+// Offsets are relative to the start of the body. The record is written and
+// read as an opaque blob; callers encrypt it like any other chunk, which
+// also authenticates the size.
+
+// EncodeIndex serializes the record offsets and plaintext size of a body.
+func EncodeIndex(offsets []int64, size int64) []byte {
+	buf := make([]byte, lengthSize+offsetSize*(len(offsets)+1))
+	binary.BigEndian.PutUint32(buf, uint32(len(offsets)))
+	for i, offset := range offsets {
+		binary.BigEndian.PutUint64(buf[lengthSize+offsetSize*i:], uint64(offset))
+	}
+	binary.BigEndian.PutUint64(buf[len(buf)-offsetSize:], uint64(size))
+	return buf
+}
+
+func DecodeIndex(data []byte) ([]int64, int64, error) {
+	if len(data) < lengthSize+offsetSize {
+		return nil, 0, fmt.Errorf("chunk index is truncated")
+	}
+
+	count := int(binary.BigEndian.Uint32(data))
+	if len(data) != lengthSize+offsetSize*(count+1) {
+		return nil, 0, fmt.Errorf("chunk index is corrupted: %d bytes for %d entries", len(data), count)
+	}
+
+	offsets := make([]int64, count)
+	for i := range offsets {
+		offsets[i] = int64(binary.BigEndian.Uint64(data[lengthSize+offsetSize*i:]))
+	}
+	size := int64(binary.BigEndian.Uint64(data[len(data)-offsetSize:]))
+	return offsets, size, nil
+}
+
+// ReadLocator reads the locator that ends the trailer, whose last byte is
+// at end. It returns the position of the index record relative to the body,
+// or false if the file has no index.
+func ReadLocator(reader io.ReaderAt, end int64) (int64, bool, error) {
+	if end < locatorSize {
+		return 0, false, nil
+	}
+
+	var buf [locatorSize]byte
+	if _, err := reader.ReadAt(buf[:], end-locatorSize); err != nil {
+		return 0, false, fmt.Errorf("index locator read failed: %w", err)
+	}
+	if !bytes.Equal(buf[offsetSize:], indexTag) {
+		return 0, false, nil
+	}
+	return int64(binary.BigEndian.Uint64(buf[:])), true, nil
+}
+
+// ReadIndexRecord reads the length-prefixed index record at the current
+// position, as left by ReadChunk returning io.EOF. The trailer holding it
+// is at most size bytes long.
+func ReadIndexRecord(reader io.Reader, size int64) ([]byte, error) {
+	var buf [lengthSize]byte
+	if _, err := io.ReadFull(reader, buf[:]); err != nil {
+		return nil, fmt.Errorf("index length read failed: %w", err)
+	}
+
+	length := int64(binary.BigEndian.Uint32(buf[:]))
+	if length > size-lengthSize-locatorSize {
+		return nil, fmt.Errorf("index length is corrupted")
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return nil, fmt.Errorf("index read failed: %w", err)
+	}
+	return data, nil
+}
+
+// ScanRecords rebuilds the record offsets of a body by following the
+// record headers alone, for files written without an index. The reader
+// must be positioned at the start of the body; scanning stops at the
+// end-of-body marker or, if limit is positive, at that body offset.
+func ScanRecords(reader io.ReadSeeker, totalShards int, stripeWidth int, limit int64) ([]int64, error) {
+	start, err := reader.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, fmt.Errorf("seek failed: %w", err)
+	}
+
+	r := NewReader(reader, totalShards, stripeWidth)
+	var offsets []int64
+	var position int64
+
+	for limit <= 0 || position < limit {
+		count, err := r.readLength()
+		if err != nil {
+			return nil, err
+		}
+		size := int64(lengthSize + count)
+		if isInterleaved(stripeWidth) {
+			lengths, _, err := r.readGroupLengths(count)
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+			size = int64(2*groupHeaderSize(len(lengths)) + groupSize(lengths))
+		} else if count == 0 {
+			if err := r.readEndTag(); err != io.EOF {
+				return nil, err
+			}
+			break
+		}
+
+		offsets = append(offsets, position)
+		position += size
+		if _, err := reader.Seek(start+position, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("seek failed: %w", err)
+		}
+	}
+
+	return offsets, nil
+}
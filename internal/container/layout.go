@@ -0,0 +1,103 @@
+package container
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+const (
+	lengthSize   = 4
+	checksumSize = 4
+)
+
+// LengthPrefixSize is the size of the length prefix of every record.
+const LengthPrefixSize = lengthSize
+
+// endTag follows the zero length of the end-of-body marker, so that a
+// length prefix wiped by damage is not mistaken for the end of the file.
+var endTag = []byte("GEND")
+
+// A file body is a sequence of records. In the contiguous layout every
+// record is a single chunk prefixed with its length. In the interleaved
+// layout every record is a stripe group:
+//
+//	header | shard 0 of each chunk | shard 1 of each chunk | ... | header
+//	header = count | length[0..count) | crc32
+//
+// so a contiguous burst of damage hits at most one shard of each chunk in
+// the group, provided it is shorter than the shards of the other chunks.
+// The header is stored on both sides of the data for the same reason: a
+// burst that destroys one copy leaves the other intact.
+//
+// In both layouts the body ends with a zero length (or zero count) and the
+// end tag, which lets readers tell a complete file from a truncated one and leaves room
+// for trailers after the body.
+
+func isInterleaved(stripeWidth int) bool {
+	return stripeWidth > 1
+}
+
+func groupHeaderSize(count int) int {
+	return lengthSize*(count+1) + checksumSize
+}
+
+func groupSize(lengths []int) int {
+	var total int
+	for _, length := range lengths {
+		total += length
+	}
+	return total
+}
+
+func encodeGroupHeader(lengths []int) []byte {
+	buf := make([]byte, groupHeaderSize(len(lengths)))
+	binary.BigEndian.PutUint32(buf, uint32(len(lengths)))
+	for i, length := range lengths {
+		binary.BigEndian.PutUint32(buf[lengthSize*(i+1):], uint32(length))
+	}
+	body := buf[:len(buf)-checksumSize]
+	binary.BigEndian.PutUint32(buf[len(body):], crc32.ChecksumIEEE(body))
+	return buf
+}
+
+func validateGroupHeader(buf []byte) error {
+	body := buf[:len(buf)-checksumSize]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(buf[len(body):]) {
+		return fmt.Errorf("stripe group header is corrupted")
+	}
+	return nil
+}
+
+func interleave(chunks [][]byte, totalShards int) []byte {
+	var total int
+	for _, chunk := range chunks {
+		total += len(chunk)
+	}
+
+	result := make([]byte, 0, total)
+	for shard := range totalShards {
+		for _, chunk := range chunks {
+			shardSize := len(chunk) / totalShards
+			result = append(result, chunk[shard*shardSize:(shard+1)*shardSize]...)
+		}
+	}
+	return result
+}
+
+func deinterleave(body []byte, lengths []int, totalShards int) [][]byte {
+	chunks := make([][]byte, len(lengths))
+	for i, length := range lengths {
+		chunks[i] = make([]byte, 0, length)
+	}
+
+	offset := 0
+	for range totalShards {
+		for i, length := range lengths {
+			shardSize := length / totalShards
+			chunks[i] = append(chunks[i], body[offset:offset+shardSize]...)
+			offset += shardSize
+		}
+	}
+	return chunks
+}
@@ -0,0 +1,311 @@
+package container
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+type Reader struct {
+	reader      io.Reader
+	totalShards int
+	stripeWidth int
+	queue       [][]byte
+	done        bool
+	chunkLimit  int
+	shardCheck  func(stored []byte) bool
+	buffered    []byte
+	groups      int
+	damaged     []int
+}
+
+func NewReader(reader io.Reader, totalShards int, stripeWidth int) *Reader {
+	return &Reader{
+		reader:      reader,
+		totalShards: totalShards,
+		stripeWidth: stripeWidth,
+	}
+}
+
+// WithChunkLimit bounds the length of a single encoded chunk. Longer
+// lengths are reported as damage, and it limits how far the reader searches
+// for the copy of a damaged stripe group header. Without a limit it may
+// search to the end of the input.
+func (r *Reader) WithChunkLimit(limit int) *Reader {
+	r.chunkLimit = limit
+	return r
+}
+
+// Buffered returns the bytes read from the underlying reader past the last
+// record returned. It is empty unless a damaged group header was recovered
+// from a reader that cannot seek.
+func (r *Reader) Buffered() []byte {
+	return r.buffered
+}
+
+// DamagedHeaders returns the stripe groups read so far, numbered from zero,
+// that had one of their two header copies damaged. Their chunks were still
+// read, through the intact copy.
+func (r *Reader) DamagedHeaders() []int {
+	return r.damaged
+}
+
+// ReadChunk returns the next encoded chunk in file order, de-interleaving
+// stripe groups as needed. It returns io.EOF at the end-of-body marker and
+// leaves the underlying reader positioned just past it.
+func (r *Reader) ReadChunk() ([]byte, error) {
+	if len(r.queue) == 0 {
+		if r.done {
+			return nil, io.EOF
+		}
+		if err := r.fill(); err != nil {
+			return nil, err
+		}
+	}
+
+	chunk := r.queue[0]
+	r.queue = r.queue[1:]
+	return chunk, nil
+}
+
+func (r *Reader) fill() error {
+	count, err := r.readLength()
+	if err != nil {
+		return err
+	}
+	if !isInterleaved(r.stripeWidth) {
+		if count == 0 {
+			return r.readEndTag()
+		}
+
+		if err := r.checkLength(count); err != nil {
+			return fmt.Errorf("chunk length is corrupted: %w", err)
+		}
+
+		data := make([]byte, count)
+		if err := r.read(data); err != nil {
+			return fmt.Errorf("chunk data read failed: %w", err)
+		}
+		r.queue = append(r.queue, data)
+		return nil
+	}
+
+	lengths, body, intact, err := r.readGroup(count)
+	if err != nil {
+		return err
+	}
+	if !intact {
+		r.damaged = append(r.damaged, r.groups)
+	}
+	r.groups++
+	r.queue = deinterleave(body, lengths, r.totalShards)
+	return nil
+}
+
+// readGroup reads the rest of a stripe group, or of the end-of-body marker,
+// whose count has been read. It returns the chunk lengths, the interleaved
+// data and whether both copies of the group header are intact.
+func (r *Reader) readGroup(count int) ([]int, []byte, bool, error) {
+	lengths, body, err := r.readGroupLengths(count)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if body != nil {
+		// The primary copy was damaged and the trailing one used instead
+		return lengths, body, false, nil
+	}
+
+	total := groupSize(lengths)
+	body = make([]byte, total+groupHeaderSize(count))
+	if err := r.read(body); err != nil {
+		return nil, nil, false, fmt.Errorf("stripe data read failed: %w", err)
+	}
+	intact := bytes.Equal(body[total:], encodeGroupHeader(lengths))
+	return lengths, body[:total], intact, nil
+}
+
+// readGroupLengths reads the header of a stripe group whose count has been
+// read, falling back to the copy after the data if it is damaged. The data
+// is returned too when it had to be read to find the copy.
+func (r *Reader) readGroupLengths(count int) ([]int, []byte, error) {
+	if count == 0 {
+		tag := make([]byte, len(endTag))
+		if err := r.read(tag); err != nil {
+			return nil, nil, fmt.Errorf("end marker read failed: %w", err)
+		}
+		if bytes.Equal(tag, endTag) {
+			r.done = true
+			return nil, nil, io.EOF
+		}
+		seen := append(make([]byte, lengthSize), tag...)
+		return r.recoverGroup(seen, fmt.Errorf("chunk length is corrupted"))
+	}
+
+	lengths, seen, err := r.readGroupHeader(count)
+	if err != nil {
+		return r.recoverGroup(seen, err)
+	}
+	return lengths, nil, nil
+}
+
+// recoverGroup searches for the copy of a damaged group header, which
+// follows the group data. seen holds the bytes of the group read so far,
+// starting with the count. The copy must describe a group that ends right
+// where it does, which a stray checksum match in the data will not.
+func (r *Reader) recoverGroup(seen []byte, cause error) ([]int, []byte, error) {
+	limit := -1
+	if r.chunkLimit > 0 {
+		limit = 2*groupHeaderSize(r.stripeWidth) + r.stripeWidth*r.chunkLimit
+	}
+
+	buf := seen
+	block := make([]byte, 64*1024)
+	for end := 0; limit < 0 || end <= limit; end++ {
+		for end >= len(buf) {
+			n, err := r.readSome(block)
+			if n == 0 && err != nil {
+				r.unread(buf[len(seen):])
+				return nil, nil, fmt.Errorf("%w, and no intact copy follows it", cause)
+			}
+			buf = append(buf, block[:n]...)
+		}
+
+		if lengths, start := r.matchGroupCopy(buf[:end+1]); lengths != nil {
+			r.unread(buf[end+1:])
+			return lengths, buf[groupHeaderSize(len(lengths)):start], nil
+		}
+	}
+
+	// Give the data back, so Resync can look for the next group in it
+	r.unread(buf[len(seen):])
+	return nil, nil, fmt.Errorf("%w, and no intact copy follows it", cause)
+}
+
+// matchGroupCopy reports the lengths of the group whose header copy ends
+// buf, together with where the copy starts.
+func (r *Reader) matchGroupCopy(buf []byte) ([]int, int) {
+	for count := 1; count <= r.stripeWidth; count++ {
+		size := groupHeaderSize(count)
+		start := len(buf) - size
+		if start < size {
+			break
+		}
+		candidate := buf[start:]
+		if int(binary.BigEndian.Uint32(candidate)) != count || validateGroupHeader(candidate) != nil {
+			continue
+		}
+
+		lengths, err := r.parseLengths(candidate)
+		if err == nil && size+groupSize(lengths) == start {
+			return lengths, start
+		}
+	}
+	return nil, 0
+}
+
+// unread gives back bytes read past the group, by seeking back if the
+// underlying reader allows it.
+func (r *Reader) unread(rest []byte) {
+	if len(rest) == 0 {
+		return
+	}
+	if len(r.buffered) == 0 {
+		if seeker, ok := r.reader.(io.Seeker); ok {
+			if _, err := seeker.Seek(-int64(len(rest)), io.SeekCurrent); err == nil {
+				return
+			}
+		}
+	}
+	r.buffered = append(bytes.Clone(rest), r.buffered...)
+}
+
+// read fills p, taking bytes given back by unread first.
+func (r *Reader) read(p []byte) error {
+	n := copy(p, r.buffered)
+	r.buffered = r.buffered[n:]
+	if n == len(p) {
+		return nil
+	}
+
+	_, err := io.ReadFull(r.reader, p[n:])
+	if err == io.EOF && n > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (r *Reader) readSome(p []byte) (int, error) {
+	if len(r.buffered) > 0 {
+		n := copy(p, r.buffered)
+		r.buffered = r.buffered[n:]
+		return n, nil
+	}
+	return r.reader.Read(p)
+}
+
+func (r *Reader) readEndTag() error {
+	tag := make([]byte, len(endTag))
+	if err := r.read(tag); err != nil {
+		return fmt.Errorf("end marker read failed: %w", err)
+	}
+	if !bytes.Equal(tag, endTag) {
+		return fmt.Errorf("chunk length is corrupted")
+	}
+
+	r.done = true
+	return io.EOF
+}
+
+// readLength reads the leading length of a record, which is the chunk
+// length in the contiguous layout and the chunk count in a stripe group.
+func (r *Reader) readLength() (int, error) {
+	var buf [lengthSize]byte
+	err := r.read(buf[:])
+	if err == io.EOF {
+		return 0, fmt.Errorf("unexpected end of file: missing end-of-body marker")
+	} else if err != nil {
+		return 0, fmt.Errorf("chunk size read failed: %w", err)
+	}
+	return int(binary.BigEndian.Uint32(buf[:])), nil
+}
+
+// readGroupHeader reads the header of a stripe group whose count has been
+// read. It also returns the bytes of the header that were read.
+func (r *Reader) readGroupHeader(count int) ([]int, []byte, error) {
+	seen := binary.BigEndian.AppendUint32(nil, uint32(count))
+	if count > r.stripeWidth {
+		return nil, seen, fmt.Errorf("stripe group header is corrupted: %d chunks in a group of %d", count, r.stripeWidth)
+	}
+
+	buf := make([]byte, groupHeaderSize(count))
+	copy(buf, seen)
+	if err := r.read(buf[lengthSize:]); err != nil {
+		return nil, nil, fmt.Errorf("stripe header read failed: %w", err)
+	}
+	if err := validateGroupHeader(buf); err != nil {
+		return nil, buf, err
+	}
+
+	lengths, err := r.parseLengths(buf)
+	return lengths, buf, err
+}
+
+func (r *Reader) parseLengths(buf []byte) ([]int, error) {
+	count := int(binary.BigEndian.Uint32(buf))
+	lengths := make([]int, count)
+	for i := range lengths {
+		lengths[i] = int(binary.BigEndian.Uint32(buf[lengthSize*(i+1):]))
+		if err := r.checkLength(lengths[i]); err != nil {
+			return nil, fmt.Errorf("stripe group header is corrupted: %w", err)
+		}
+	}
+	return lengths, nil
+}
+
+func (r *Reader) checkLength(length int) error {
+	if length == 0 || length%r.totalShards != 0 || (r.chunkLimit > 0 && length > r.chunkLimit) {
+		return fmt.Errorf("invalid chunk length %d", length)
+	}
+	return nil
+}
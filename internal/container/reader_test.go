@@ -0,0 +1,185 @@
+package container
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"slices"
+	"testing"
+)
+
+const (
+	testShards = 14
+	testWidth  = 4
+)
+
+// writeTestBody writes chunks of different lengths in stripe groups of
+// testWidth and returns the body with the chunks.
+func writeTestBody(t *testing.T, count int) ([]byte, [][]byte) {
+	t.Helper()
+	var body bytes.Buffer
+	w := NewWriter(&body, testShards, testWidth)
+
+	var chunks [][]byte
+	for i := range count {
+		chunk := make([]byte, testShards*(50+i))
+		if _, err := rand.Read(chunk); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.WriteChunk(chunk); err != nil {
+			t.Fatal(err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return body.Bytes(), chunks
+}
+
+// groupHeaders returns where both copies of the header of every stripe
+// group start.
+func groupHeaders(body []byte) [][2]int {
+	var headers [][2]int
+	for at := 0; ; {
+		count := int(binary.BigEndian.Uint32(body[at:]))
+		if count == 0 {
+			return headers
+		}
+		lengths := make([]int, count)
+		for i := range lengths {
+			lengths[i] = int(binary.BigEndian.Uint32(body[at+lengthSize*(i+1):]))
+		}
+		size := groupHeaderSize(count)
+		copyAt := at + size + groupSize(lengths)
+		headers = append(headers, [2]int{at, copyAt})
+		at = copyAt + size
+	}
+}
+
+// onlyReader hides the Seek method of the reader it wraps.
+type onlyReader struct {
+	io.Reader
+}
+
+func readAll(t *testing.T, r *Reader) ([][]byte, error) {
+	t.Helper()
+	var chunks [][]byte
+	for {
+		chunk, err := r.ReadChunk()
+		if err == io.EOF {
+			return chunks, nil
+		}
+		if err != nil {
+			return chunks, err
+		}
+		chunks = append(chunks, chunk)
+	}
+}
+
+func TestReaderGroupHeaderCopies(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt []int // 0 for the primary copy, 1 for the trailing one
+		damaged []int
+	}{
+		{name: "intact"},
+		{name: "primary", corrupt: []int{0}, damaged: []int{1}},
+		{name: "copy", corrupt: []int{1}, damaged: []int{1}},
+	}
+
+	for _, tt := range tests {
+		for _, seekable := range []bool{true, false} {
+			body, want := writeTestBody(t, 10)
+			header := groupHeaders(body)[1]
+			for _, which := range tt.corrupt {
+				body[header[which]+lengthSize] ^= 0xff
+			}
+
+			var input io.Reader = bytes.NewReader(body)
+			if !seekable {
+				input = onlyReader{input}
+			}
+			r := NewReader(input, testShards, testWidth).WithChunkLimit(testShards * 100)
+			got, err := readAll(t, r)
+			if err != nil {
+				t.Fatalf("%s: reading failed: %v", tt.name, err)
+			}
+			if !slices.EqualFunc(got, want, bytes.Equal) {
+				t.Fatalf("%s: chunks do not match what was written", tt.name)
+			}
+			if !slices.Equal(r.DamagedHeaders(), tt.damaged) {
+				t.Fatalf("%s: damaged headers %v, want %v", tt.name, r.DamagedHeaders(), tt.damaged)
+			}
+		}
+	}
+}
+
+func TestReaderGroupHeaderBothCopiesDamaged(t *testing.T) {
+	body, _ := writeTestBody(t, 10)
+	header := groupHeaders(body)[1]
+	body[header[0]+lengthSize] ^= 0xff
+	body[header[1]+lengthSize] ^= 0xff
+
+	r := NewReader(bytes.NewReader(body), testShards, testWidth).WithChunkLimit(testShards * 100)
+	got, err := readAll(t, r)
+	if err == nil {
+		t.Fatal("reading succeeded with both copies of a group header damaged")
+	}
+	if len(got) != testWidth {
+		t.Fatalf("read %d chunks before the damaged group, want %d", len(got), testWidth)
+	}
+}
+
+func TestReframeChunk(t *testing.T) {
+	var body bytes.Buffer
+	w := NewWriter(&body, testShards, 0)
+	var chunks [][]byte
+	for i := range 4 {
+		chunk := make([]byte, testShards*(50+i))
+		if _, err := rand.Read(chunk); err != nil {
+			t.Fatal(err)
+		}
+		// Every shard ends in its checksum, which the check below verifies
+		for s := range testShards {
+			shard := chunk[s*(50+i) : (s+1)*(50+i)]
+			binary.BigEndian.PutUint32(shard[len(shard)-4:], testChecksum(shard[:len(shard)-4]))
+		}
+		if err := w.WriteChunk(chunk); err != nil {
+			t.Fatal(err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	check := func(stored []byte) bool {
+		return testChecksum(stored[:len(stored)-4]) == binary.BigEndian.Uint32(stored[len(stored)-4:])
+	}
+
+	start := int64(2*lengthSize + len(chunks[0]) + len(chunks[1]))
+	for _, length := range []uint32{0xffffff, uint32(len(chunks[2]) - 2*testShards)} {
+		data := bytes.Clone(body.Bytes())
+		binary.BigEndian.PutUint32(data[start:], length)
+
+		reader := bytes.NewReader(data)
+		got, err := ReframeChunk(reader, start, testShards, testShards*100, check)
+		if err != nil {
+			t.Fatalf("length %d: reframing failed: %v", length, err)
+		}
+		if !bytes.Equal(got, chunks[2]) {
+			t.Fatalf("length %d: reframed chunk does not match what was written", length)
+		}
+
+		next, err := NewReader(reader, testShards, 0).ReadChunk()
+		if err != nil || !bytes.Equal(next, chunks[3]) {
+			t.Fatalf("length %d: reader is not left at the next record: %v", length, err)
+		}
+	}
+}
+
+func testChecksum(payload []byte) uint32 {
+	return crc32.Checksum(payload, crc32.MakeTable(crc32.Castagnoli))
+}
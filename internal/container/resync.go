@@ -0,0 +1,157 @@
+package container
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// resyncCompact is how far Resync scans before it drops the bytes behind it.
+const resyncCompact = 1024 * 1024
+
+// WithShardCheck sets how Resync tells an intact stored shard from damaged
+// data. Without it a plausible length or group header alone is accepted.
+func (r *Reader) WithShardCheck(check func(stored []byte) bool) *Reader {
+	r.shardCheck = check
+	return r
+}
+
+// Discard skips n bytes of the body, after ReadChunk failed, to where the
+// chunk index says the next record starts.
+func (r *Reader) Discard(n int64) error {
+	r.queue = nil
+	skip := min(n, int64(len(r.buffered)))
+	r.buffered = r.buffered[skip:]
+
+	if _, err := io.CopyN(io.Discard, r.reader, n-skip); err != nil {
+		return fmt.Errorf("skipping damaged data failed: %w", err)
+	}
+	return nil
+}
+
+// Resync scans forward, after ReadChunk failed on damaged framing, to the
+// next record whose first shard is intact or to the end-of-body marker,
+// and leaves the reader there. It returns the number of bytes skipped.
+func (r *Reader) Resync() (int64, error) {
+	r.queue = nil
+	var buf []byte
+	var skipped int64
+	block := make([]byte, 64*1024)
+	eof := false
+
+	for start := 0; ; start++ {
+		if start >= resyncCompact {
+			buf = append(buf[:0], buf[start:]...)
+			skipped += int64(start)
+			start = 0
+		}
+
+		for {
+			ok, need := r.recordAt(buf[start:])
+			if ok {
+				r.unread(buf[start:])
+				return skipped + int64(start), nil
+			}
+			if need <= len(buf)-start || eof {
+				break
+			}
+
+			n, err := r.readSome(block)
+			buf = append(buf, block[:n]...)
+			if err == io.EOF {
+				eof = true
+			} else if err != nil {
+				return skipped + int64(start), fmt.Errorf("read failed while resynchronising: %w", err)
+			}
+		}
+
+		if eof && start >= len(buf) {
+			return skipped + int64(start), fmt.Errorf("no intact record follows the damage")
+		}
+	}
+}
+
+// ReframeChunk reads the chunk of the contiguous-layout record at offset
+// start of reader when its length prefix is corrupted. The chunk is taken to
+// run up to the next record whose first shard passes check, and the reader
+// is left there. Chunks are at most limit bytes long.
+func ReframeChunk(reader io.ReadSeeker, start int64, totalShards int, limit int, check func(stored []byte) bool) ([]byte, error) {
+	if _, err := reader.Seek(start+lengthSize, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seek failed: %w", err)
+	}
+
+	r := NewReader(reader, totalShards, 0).WithChunkLimit(limit).WithShardCheck(check)
+	length, err := r.Resync()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.checkLength(int(min(length, int64(limit)+1))); err != nil {
+		return nil, fmt.Errorf("no record follows at a valid chunk length: %w", err)
+	}
+
+	if _, err := reader.Seek(start+lengthSize, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seek failed: %w", err)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return nil, fmt.Errorf("chunk data read failed: %w", err)
+	}
+	return data, nil
+}
+
+// recordAt reports whether buf starts with an intact record or the
+// end-of-body marker. If it cannot tell yet, need is how many bytes it
+// wants to see.
+func (r *Reader) recordAt(buf []byte) (bool, int) {
+	if len(buf) < lengthSize {
+		return false, lengthSize
+	}
+
+	count := int(binary.BigEndian.Uint32(buf))
+	if count == 0 {
+		need := lengthSize + len(endTag)
+		return len(buf) >= need && bytes.Equal(buf[lengthSize:need], endTag), need
+	}
+
+	if !isInterleaved(r.stripeWidth) {
+		if r.checkLength(count) != nil {
+			return false, 0
+		}
+		ok, need := r.shardAt(buf[lengthSize:], count)
+		return ok, lengthSize + need
+	}
+
+	if count > r.stripeWidth {
+		return false, 0
+	}
+	size := groupHeaderSize(count)
+	if len(buf) < size {
+		return false, size
+	}
+	if validateGroupHeader(buf[:size]) != nil {
+		return false, 0
+	}
+	lengths, err := r.parseLengths(buf[:size])
+	if err != nil {
+		return false, 0
+	}
+
+	// The group data starts with the first shard of its first chunk
+	ok, need := r.shardAt(buf[size:], lengths[0])
+	return ok, size + need
+}
+
+// shardAt checks the first shard of a chunk of length bytes at the start
+// of buf.
+func (r *Reader) shardAt(buf []byte, length int) (bool, int) {
+	if r.shardCheck == nil {
+		return true, 0
+	}
+
+	need := length / r.totalShards
+	if len(buf) < need {
+		return false, need
+	}
+	return r.shardCheck(buf[:need]), need
+}
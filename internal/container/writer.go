@@ -0,0 +1,128 @@
+package container
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+type Writer struct {
+	writer      io.Writer
+	totalShards int
+	stripeWidth int
+	pending     [][]byte
+	position    int64
+	offsets     []int64
+}
+
+func NewWriter(writer io.Writer, totalShards int, stripeWidth int) *Writer {
+	return &Writer{
+		writer:      writer,
+		totalShards: totalShards,
+		stripeWidth: stripeWidth,
+	}
+}
+
+// Resume continues a body of which the records at offsets, position bytes
+// in all, have already been written, so that the index covers them too.
+func (w *Writer) Resume(offsets []int64, position int64) *Writer {
+	w.offsets = append(w.offsets[:0], offsets...)
+	w.position = position
+	return w
+}
+
+// Offsets returns the body offset of every record written so far.
+func (w *Writer) Offsets() []int64 {
+	return w.offsets
+}
+
+func (w *Writer) WriteChunk(data []byte) error {
+	if len(data)%w.totalShards != 0 {
+		return fmt.Errorf("chunk length %d is not a multiple of %d shards", len(data), w.totalShards)
+	}
+
+	if !isInterleaved(w.stripeWidth) {
+		return w.writeRecord(data)
+	}
+
+	w.pending = append(w.pending, data)
+	if len(w.pending) < w.stripeWidth {
+		return nil
+	}
+	return w.flush()
+}
+
+// Close writes any buffered chunks as a (possibly short) stripe group,
+// followed by the end-of-body marker. It does not close the underlying
+// writer.
+func (w *Writer) Close() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+
+	marker := make([]byte, lengthSize, lengthSize+len(endTag))
+	if err := w.write(append(marker, endTag...)); err != nil {
+		return fmt.Errorf("end marker write failed: %w", err)
+	}
+	return nil
+}
+
+// WriteIndex writes the index record and its locator after Close.
+func (w *Writer) WriteIndex(record []byte) error {
+	buf := make([]byte, lengthSize, lengthSize+len(record)+locatorSize)
+	binary.BigEndian.PutUint32(buf, uint32(len(record)))
+	buf = append(buf, record...)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(w.position))
+	buf = append(buf, indexTag...)
+
+	if err := w.write(buf); err != nil {
+		return fmt.Errorf("index write failed: %w", err)
+	}
+	return nil
+}
+
+func (w *Writer) flush() error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+
+	lengths := make([]int, len(w.pending))
+	for i, chunk := range w.pending {
+		lengths[i] = len(chunk)
+	}
+
+	header := encodeGroupHeader(lengths)
+	w.offsets = append(w.offsets, w.position)
+	if err := w.write(header); err != nil {
+		return fmt.Errorf("stripe header write failed: %w", err)
+	}
+	if err := w.write(interleave(w.pending, w.totalShards)); err != nil {
+		return fmt.Errorf("stripe write failed: %w", err)
+	}
+	if err := w.write(header); err != nil {
+		return fmt.Errorf("stripe header copy write failed: %w", err)
+	}
+
+	w.pending = w.pending[:0]
+	return nil
+}
+
+func (w *Writer) writeRecord(data []byte) error {
+	var buf [lengthSize]byte
+	binary.BigEndian.PutUint32(buf[:], uint32(len(data)))
+
+	w.offsets = append(w.offsets, w.position)
+	if err := w.write(buf[:]); err != nil {
+		return fmt.Errorf("chunk size write failed: %w", err)
+	}
+	if err := w.write(data); err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+	return nil
+}
+
+func (w *Writer) write(data []byte) error {
+	n, err := w.writer.Write(data)
+	w.position += int64(n)
+	return err
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	"github.com/hambosto/go-encryption/internal/progress"
+)
+
+// BatchResult is the outcome of one file of a batch.
+type BatchResult struct {
+	Input  string
+	Output string
+	Err    error
+}
+
+// ProcessBatch runs every config, jobs files at a time, and carries on past
+// files that fail. The CPUs are split between the files running at once,
+// the password is asked for once and reused for every config that has none,
+// and the prompts of concurrent files are asked one after another. Files
+// not started before ctx is cancelled report its error.
+func (op *Operations) ProcessBatch(ctx context.Context, configs []OperationConfig, jobs int) []BatchResult {
+	jobs = max(min(jobs, len(configs)), 1)
+	batch := NewOperation(op.fileManager, &sharedPrompt{PromptInterface: op.userPrompt})
+
+	results := make([]BatchResult, len(configs))
+	slots := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+
+	for i, config := range configs {
+		results[i] = BatchResult{Input: config.InputPath, Output: config.OutputPath}
+
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			results[i].Err = ctx.Err()
+			continue
+		}
+
+		if config.Workers == 0 {
+			config.Workers = max(runtime.NumCPU()/jobs, 1)
+		}
+		// Progress bars of concurrent files would overwrite each other
+		if config.Progress == nil && jobs > 1 {
+			config.Progress = progress.Silent{}
+		}
+
+		wg.Add(1)
+		go func(i int, config OperationConfig) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			results[i].Err = batch.ProcessContext(ctx, config)
+		}(i, config)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// sharedPrompt serialises the questions of concurrent files and asks for
+// the password only once.
+type sharedPrompt struct {
+	PromptInterface
+	mu       sync.Mutex
+	asked    bool
+	password string
+	err      error
+}
+
+func (p *sharedPrompt) GetPassword() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// A failed prompt is not repeated for every remaining file
+	if !p.asked {
+		p.password, p.err = p.PromptInterface.GetPassword()
+		p.asked = true
+	}
+	return p.password, p.err
+}
+
+func (p *sharedPrompt) ConfirmOverwrite(path string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.PromptInterface.ConfirmOverwrite(path)
+}
+
+func (p *sharedPrompt) ConfirmResume(path string) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.PromptInterface.ConfirmResume(path)
+}
+
+func (p *sharedPrompt) ConfirmDelete(path string, prompt string) (bool, DeleteType, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.PromptInterface.ConfirmDelete(path, prompt)
+}
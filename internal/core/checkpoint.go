@@ -0,0 +1,212 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"time"
+
+	"github.com/hambosto/go-encryption/internal/container"
+	"github.com/hambosto/go-encryption/internal/encoding"
+	"github.com/hambosto/go-encryption/internal/header"
+	"github.com/hambosto/go-encryption/internal/worker"
+)
+
+const (
+	checkpointExtension = ".checkpoint.json"
+
+	// checkpointInterval bounds how much work an interruption can cost.
+	// Files smaller than this never leave a partial output behind.
+	checkpointInterval = 256 * 1024 * 1024
+)
+
+// checkpointState is kept next to the output while a checkpointed run is in
+// progress. KeyCheck lets a resumed run reject a different password, and
+// OutputHash a partial output changed since, before it appends anything to
+// the output.
+type checkpointState struct {
+	Operation    OperationType     `json:"operation"`
+	Input        string            `json:"input"`
+	InputSize    int64             `json:"input_size"`
+	InputModTime time.Time         `json:"input_mod_time"`
+	KeyCheck     string            `json:"key_check"`
+	OutputHash   string            `json:"output_hash"`
+	Progress     worker.Checkpoint `json:"progress"`
+}
+
+// outputHash is the SHA-256 of the output of a checkpointed run, from start
+// up to the latest checkpoint. Each checkpoint only hashes what was written
+// since the one before.
+type outputHash struct {
+	digest hash.Hash
+	start  int64
+	end    int64
+}
+
+func newOutputHash(start int64) *outputHash {
+	return &outputHash{digest: sha256.New(), start: start, end: start}
+}
+
+func (h *outputHash) advance(output *os.File, end int64) error {
+	if _, err := io.Copy(h.digest, io.NewSectionReader(output, h.end, end-h.end)); err != nil {
+		return fmt.Errorf("failed to hash output: %w", err)
+	}
+	h.end = end
+	return nil
+}
+
+func (h *outputHash) sum() string {
+	return hex.EncodeToString(h.digest.Sum(nil))
+}
+
+func checkpointPath(output string) string {
+	return output + checkpointExtension
+}
+
+// CheckpointExists reports whether an interrupted run left a checkpoint for
+// the given output path.
+func CheckpointExists(output string) bool {
+	_, err := os.Stat(checkpointPath(output))
+	return err == nil
+}
+
+func newCheckpointState(config OperationConfig, inputInfo os.FileInfo, key []byte) checkpointState {
+	return checkpointState{
+		Operation:    config.Operation,
+		Input:        config.InputPath,
+		InputSize:    inputInfo.Size(),
+		InputModTime: inputInfo.ModTime(),
+		KeyCheck:     keyCheck(key),
+	}
+}
+
+func keyCheck(key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("go-encryption checkpoint"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func loadCheckpoint(config OperationConfig, inputInfo os.FileInfo) (checkpointState, error) {
+	var state checkpointState
+
+	data, err := os.ReadFile(checkpointPath(config.OutputPath))
+	if err != nil {
+		return state, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("failed to decode checkpoint: %w", err)
+	}
+
+	if state.Operation != config.Operation {
+		return state, fmt.Errorf("checkpoint belongs to %s, not %s", state.Operation, config.Operation)
+	}
+	if state.InputSize != inputInfo.Size() || !state.InputModTime.Equal(inputInfo.ModTime()) {
+		return state, fmt.Errorf("%s has changed since the checkpoint was written", config.InputPath)
+	}
+	return state, nil
+}
+
+func (s checkpointState) verifyKey(key []byte) error {
+	if !hmac.Equal([]byte(s.KeyCheck), []byte(keyCheck(key))) {
+		return fmt.Errorf("password does not match the interrupted run")
+	}
+	return nil
+}
+
+func saveCheckpoint(path string, state checkpointState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+
+	// Replace the previous checkpoint atomically, so an interruption while
+	// saving leaves the older one intact
+	temp := path + ".tmp"
+	if err := os.WriteFile(temp, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(temp, path); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// checkpointer syncs the output before each checkpoint is saved, so the
+// sidecar never points past data that has not reached the disk. hashed
+// covers the output up to where the run starts.
+func checkpointer(config OperationConfig, output *os.File, state checkpointState, hashed *outputHash) worker.CheckpointFunc {
+	path := checkpointPath(config.OutputPath)
+	return func(progress worker.Checkpoint) error {
+		if err := output.Sync(); err != nil {
+			return fmt.Errorf("failed to sync output: %w", err)
+		}
+		if err := hashed.advance(output, hashed.start+progress.OutputOffset); err != nil {
+			return err
+		}
+		state.OutputHash = hashed.sum()
+		state.Progress = progress
+		return saveCheckpoint(path, state)
+	}
+}
+
+// seekToCheckpoint positions input and output for a resumed run, once the
+// partial output has been checked against the hash in the checkpoint. The
+// body of an encrypted file starts after its header, so inputStart and
+// outputStart give the offset the checkpoint offsets are relative to. It
+// returns the hash of the output so far for the checkpoints to come.
+func seekToCheckpoint(input *os.File, inputStart int64, output *os.File, outputStart int64, state checkpointState) (*outputHash, error) {
+	progress := state.Progress
+	if _, err := input.Seek(inputStart+progress.InputOffset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to seek input: %w", err)
+	}
+
+	end := outputStart + progress.OutputOffset
+	info, err := output.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat output: %w", err)
+	}
+	if info.Size() < end {
+		return nil, fmt.Errorf("partial output is shorter than the checkpoint: %d of %d bytes", info.Size(), end)
+	}
+
+	hashed := newOutputHash(outputStart)
+	if err := hashed.advance(output, end); err != nil {
+		return nil, err
+	}
+	if hashed.sum() != state.OutputHash {
+		return nil, fmt.Errorf("partial output has changed since the checkpoint was written")
+	}
+
+	// Drop whatever was written after the checkpoint
+	if err := output.Truncate(end); err != nil {
+		return nil, fmt.Errorf("failed to truncate output: %w", err)
+	}
+	if _, err := output.Seek(end, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to seek output: %w", err)
+	}
+	return hashed, nil
+}
+
+// resumedRecords finds the records of the partial output before the
+// checkpoint, which the chunk index written at the end must include.
+func resumedRecords(output *os.File, fileHeader header.Header, progress worker.Checkpoint) ([]int64, error) {
+	if _, err := output.Seek(bodyStart(), io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to seek output: %w", err)
+	}
+
+	rs := encoding.DefaultConfig()
+	offsets, err := container.ScanRecords(output, rs.DataShards+rs.ParityShards, int(fileHeader.StripeWidth.Value), progress.OutputOffset)
+	if err != nil {
+		return nil, fmt.Errorf("partial output is corrupted: %w", err)
+	}
+	return offsets, nil
+}
+
+func bodyStart() int64 {
+	return int64(header.EncodedSize())
+}
@@ -0,0 +1,134 @@
+package core
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/hambosto/go-encryption/internal/container"
+	"github.com/hambosto/go-encryption/internal/encoding"
+	"github.com/hambosto/go-encryption/internal/header"
+	"github.com/hambosto/go-encryption/internal/processor"
+)
+
+// resolveSize fills in the original size of a file written by a streaming
+// writer, whose primary header records header.UnknownSize; the footer copy
+// has the real value. The position of input is preserved.
+func resolveSize(input io.ReadSeeker, fileHeader header.Header) (header.Header, error) {
+	if fileHeader.OriginalSize.Value != header.UnknownSize {
+		return fileHeader, nil
+	}
+
+	position, err := input.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fileHeader, fmt.Errorf("seek failed: %w", err)
+	}
+
+	footer, err := header.NewHeaderReader(header.NewBinaryHeaderIO()).ReadBackup(input)
+	if err != nil {
+		return fileHeader, fmt.Errorf("original size is only recorded in the footer, which is unreadable: %w", err)
+	}
+	if footer.OriginalSize.Value == header.UnknownSize {
+		return fileHeader, fmt.Errorf("file is incomplete: its size was never recorded")
+	}
+
+	if _, err := input.Seek(position, io.SeekStart); err != nil {
+		return fileHeader, fmt.Errorf("seek failed: %w", err)
+	}
+
+	fileHeader.OriginalSize = footer.OriginalSize
+	return fileHeader, nil
+}
+
+// checkedChunk is an encoded chunk of the body with the result of checking
+// its shards. A reframed chunk had a corrupted length prefix.
+type checkedChunk struct {
+	data     []byte
+	damaged  []int
+	err      error
+	reframed bool
+}
+
+func newBodyReader(reader io.Reader, fileHeader header.Header, totalShards int) *container.Reader {
+	return container.NewReader(reader, totalShards, int(fileHeader.StripeWidth.Value))
+}
+
+// walkChunks calls fn with every encoded chunk of the file body in order.
+// The underlying reader must be positioned just past the header.
+func walkChunks(chunks *container.Reader, fn func(index uint32, chunk []byte) error) error {
+	for index := uint32(0); ; index++ {
+		chunk, err := chunks.ReadChunk()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading chunk %d: %w", index, err)
+		}
+
+		if err := fn(index, chunk); err != nil {
+			return err
+		}
+	}
+}
+
+// checkChunks calls fn with every chunk of the body, checked against rs.
+// chunks must read from input, positioned just past the header. In the
+// contiguous layout a chunk that cannot be read or fails its check may sit
+// behind a corrupted length prefix, so it is read again up to the next
+// intact record and taken if it checks out then.
+func checkChunks(input io.ReadSeeker, chunks *container.Reader, fileHeader header.Header, rs *encoding.ReedSolomon, fn func(index uint32, chunk checkedChunk) error) error {
+	contiguous := fileHeader.StripeWidth.Value <= 1
+	limit := processor.MaxEncodedSize(rs, int(fileHeader.ChunkSize.Value))
+
+	for index := uint32(0); ; index++ {
+		// The reader does not read ahead in the contiguous layout
+		start, err := input.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return fmt.Errorf("seek failed: %w", err)
+		}
+
+		data, err := chunks.ReadChunk()
+		if err == io.EOF {
+			return nil
+		}
+		chunk := checkedChunk{data: data}
+		if err == nil {
+			chunk.damaged, chunk.err = rs.Check(data)
+		}
+
+		if contiguous && (err != nil || chunk.err != nil) {
+			reframed, ok := reframeChunk(input, start, rs, limit, len(data))
+			switch {
+			case ok:
+				chunk = reframed
+			case err == nil:
+				// Damaged shards rather than framing; carry on after the chunk
+				if _, err := input.Seek(start+int64(container.LengthPrefixSize+len(data)), io.SeekStart); err != nil {
+					return fmt.Errorf("seek failed: %w", err)
+				}
+			}
+		}
+		if err != nil && !chunk.reframed {
+			return fmt.Errorf("reading chunk %d: %w", index, err)
+		}
+
+		if err := fn(index, chunk); err != nil {
+			return err
+		}
+	}
+}
+
+// reframeChunk reads the chunk of the record at start up to the next intact
+// record. It fails unless that gives a chunk of a different length than
+// the prefix, read bytes, whose shards check out.
+func reframeChunk(input io.ReadSeeker, start int64, rs *encoding.ReedSolomon, limit int, read int) (checkedChunk, bool) {
+	data, err := container.ReframeChunk(input, start, rs.TotalShards(), limit, encoding.ShardIntact)
+	if err != nil || len(data) == read {
+		return checkedChunk{}, false
+	}
+
+	damaged, err := rs.Check(data)
+	if err != nil {
+		return checkedChunk{}, false
+	}
+	return checkedChunk{data: data, damaged: damaged, reframed: true}, true
+}
@@ -1,7 +1,10 @@
 package core
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -14,16 +17,20 @@ type FileManagerInterface interface {
 
 type PromptInterface interface {
 	ConfirmOverwrite(path string) (bool, error)
+	ConfirmResume(path string) (bool, error)
+	ConfirmCheckpoint() (bool, error)
 	GetPassword() (string, error)
 	ConfirmDelete(path string, prompt string) (bool, DeleteType, error)
 	GetOperation() (OperationType, error)
 	SelectFile(files []string) (string, error)
+	SelectFiles(files []string) ([]string, error)
 }
 
 type Processor struct {
 	fileManager FileManagerInterface
 	userPrompt  PromptInterface
 	operation   *Operations
+	passwords   func(input, output string) (string, error)
 }
 
 func NewProcessor(fileManager FileManagerInterface, userPrompt PromptInterface) *Processor {
@@ -34,30 +41,127 @@ func NewProcessor(fileManager FileManagerInterface, userPrompt PromptInterface)
 	}
 }
 
+// WithPasswords has ProcessFilesContext take the password of every file
+// from passwords instead of prompting once for all of them. A file it
+// returns an empty password for is still prompted for.
+func (p *Processor) WithPasswords(passwords func(input, output string) (string, error)) *Processor {
+	p.passwords = passwords
+	return p
+}
+
 func (p *Processor) ProcessFile(input string, op OperationType) error {
-	config := OperationConfig{
-		InputPath:  input,
-		OutputPath: determineOutputPath(input, op),
-		Operation:  mapOperationType(op),
+	return p.ProcessFileContext(context.Background(), input, op)
+}
+
+func (p *Processor) ProcessFileContext(ctx context.Context, input string, op OperationType) error {
+	checkpoint, err := p.checkpointing(op)
+	if err != nil {
+		return err
+	}
+	config, err := p.fileConfig(input, op, checkpoint)
+	if err != nil {
+		return err
 	}
 
-	if err := p.operation.Process(config); err != nil {
+	if err := p.operation.ProcessContext(ctx, config); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func determineOutputPath(input string, op OperationType) string {
-	if op == Encrypt {
-		return input + encExtension
+// ProcessFilesContext applies op to every input, jobs files at a time, with
+// a single password prompt. A file that fails does not stop the others.
+func (p *Processor) ProcessFilesContext(ctx context.Context, inputs []string, op OperationType, jobs int) []BatchResult {
+	results := make([]BatchResult, len(inputs))
+	checkpoint, err := p.checkpointing(op)
+	if err != nil {
+		for i, input := range inputs {
+			results[i] = BatchResult{Input: input, Err: err}
+		}
+		return results
+	}
+
+	var configs []OperationConfig
+	var pending []int
+	for i, input := range inputs {
+		config, err := p.fileConfig(input, op, checkpoint)
+		if err == nil && p.passwords != nil {
+			config.Password, err = p.passwords(input, config.OutputPath)
+		}
+		if err != nil {
+			results[i] = BatchResult{Input: input, Output: config.OutputPath, Err: err}
+			continue
+		}
+		configs = append(configs, config)
+		pending = append(pending, i)
+	}
+
+	for i, result := range p.operation.ProcessBatch(ctx, configs, jobs) {
+		results[pending[i]] = result
+	}
+	return results
+}
+
+// checkpointing asks whether op should be checkpointed. Without it an
+// interrupted run removes its partial output, as it always did.
+func (p *Processor) checkpointing(op OperationType) (bool, error) {
+	if op != Encrypt && op != Decrypt {
+		return false, nil
+	}
+	checkpoint, err := p.userPrompt.ConfirmCheckpoint()
+	if err != nil {
+		return false, fmt.Errorf("checkpoint prompt failed: %w", err)
+	}
+	return checkpoint, nil
+}
+
+func (p *Processor) fileConfig(input string, op OperationType, checkpoint bool) (OperationConfig, error) {
+	config := OperationConfig{
+		InputPath:  input,
+		OutputPath: DefaultOutputPath(input, op),
+		Operation:  mapOperationType(op),
+		Checkpoint: checkpoint,
+	}
+
+	if (op == Encrypt || op == Decrypt) && CheckpointExists(config.OutputPath) {
+		resume, err := p.userPrompt.ConfirmResume(config.OutputPath)
+		if err != nil {
+			return config, fmt.Errorf("resume prompt failed: %w", err)
+		}
+		config.Resume = resume
+	}
+	return config, nil
+}
+
+// DefaultOutputPath adds the .enc extension when encrypting and strips it
+// when decrypting. Repair and Migrate work in place and Verify writes
+// nothing.
+func DefaultOutputPath(input string, op OperationType) string {
+	switch op {
+	case Encrypt:
+		// A directory may be given with a trailing separator
+		return filepath.Clean(input) + encExtension
+	case Repair, Migrate:
+		return input
+	case Verify:
+		return ""
+	default:
+		return strings.TrimSuffix(input, encExtension)
 	}
-	return strings.TrimSuffix(input, encExtension)
 }
 
 func mapOperationType(op OperationType) OperationType {
-	if op == Encrypt {
+	switch op {
+	case Encrypt:
 		return OperationEncrypt
+	case Repair:
+		return OperationRepair
+	case Verify:
+		return OperationVerify
+	case Migrate:
+		return OperationMigrate
+	default:
+		return OperationDecrypt
 	}
-	return OperationDecrypt
 }
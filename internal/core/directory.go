@@ -0,0 +1,185 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/hambosto/go-encryption/internal/archive"
+	"github.com/hambosto/go-encryption/internal/container"
+	"github.com/hambosto/go-encryption/internal/header"
+	"github.com/hambosto/go-encryption/internal/worker"
+)
+
+func isDirectory(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// handleDirectoryEncryption packs a directory into one archive and streams
+// it through the same pipeline as a file. Directories are not checkpointed.
+func (op *Operations) handleDirectoryEncryption(ctx context.Context, config OperationConfig) error {
+	if config.Resume {
+		return errors.New("directory encryption cannot be resumed")
+	}
+	config.Checkpoint = false
+
+	if err := op.validateOperation(config); err != nil {
+		return err
+	}
+
+	tree, err := archive.Plan(config.InputPath)
+	if err != nil {
+		return err
+	}
+	for _, path := range tree.Skipped() {
+		fmt.Printf("Warning: skipping %s, only files, directories and symbolic links are archived\n", path)
+	}
+
+	password := config.Password
+	if password == "" {
+		password, err = op.userPrompt.GetPassword()
+		if err != nil {
+			return fmt.Errorf("password prompt failed: %w", err)
+		}
+	}
+
+	output, err := op.openOutput(config)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+
+	key, salt, err := op.deriveKey(password)
+	if err != nil {
+		op.abandonOutput(config, output)
+		return err
+	}
+
+	fmt.Printf("Encrypting directory %s...\n", config.InputPath)
+
+	reader, writer := io.Pipe()
+	digest := sha256.New()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := tree.WriteTo(io.MultiWriter(writer, digest))
+		writer.CloseWithError(err)
+	}()
+
+	err = op.performEncryption(ctx, reader, output, tree.Size(), key, salt, config, checkpointState{})
+	reader.CloseWithError(err)
+	<-done
+	if err != nil {
+		op.abandonOutput(config, output)
+		return err
+	}
+
+	if err = op.handleCleanup(config.InputPath, true, op.roundTripCheck(ctx, config, key, digest)); err != nil {
+		return err
+	}
+
+	fmt.Printf("Directory %s encrypted to %s\n", config.InputPath, config.OutputPath)
+	return nil
+}
+
+// isArchive reports whether the plaintext starts with the directory archive
+// marker. Only the first chunk is decrypted; if that fails the file is
+// treated as an ordinary one and the failure is left to the decryption.
+func isArchive(input *os.File, key []byte, fileHeader header.Header) (bool, error) {
+	if fileHeader.OriginalSize.Value < uint64(len(archive.Magic)) {
+		return false, nil
+	}
+
+	chunks, err := newChunkDecryptor(key, fileHeader)
+	if err != nil {
+		return false, err
+	}
+	if _, err := input.Seek(bodyStart(), io.SeekStart); err != nil {
+		return false, fmt.Errorf("seek failed: %w", err)
+	}
+
+	var plain []byte
+	chunk, err := container.NewReader(input, chunks.ReedSolomon.TotalShards(), int(fileHeader.StripeWidth.Value)).ReadChunk()
+	if err == nil {
+		plain, _ = chunks.ProcessChunk(chunk)
+	}
+
+	if _, err := input.Seek(bodyStart(), io.SeekStart); err != nil {
+		return false, fmt.Errorf("seek failed: %w", err)
+	}
+	return bytes.HasPrefix(plain, archive.Magic), nil
+}
+
+// restoreDirectory decrypts a directory archive into a new directory at the
+// output path. The tree is built under a temporary name next to it and only
+// renamed into place once every chunk has been authenticated.
+func (op *Operations) restoreDirectory(ctx context.Context, input *os.File, key []byte, fileHeader header.Header, config OperationConfig) error {
+	if config.Resume || config.Salvage != worker.SalvageOff {
+		return errors.New("directory archives cannot be resumed or salvaged")
+	}
+	if _, err := os.Lstat(config.OutputPath); err == nil {
+		return fmt.Errorf("%s already exists, a directory is only restored to a new path", config.OutputPath)
+	}
+
+	temp, err := os.MkdirTemp(filepath.Dir(config.OutputPath), filepath.Base(config.OutputPath)+".restore-*")
+	if err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	fmt.Printf("Decrypting directory %s...\n", config.InputPath)
+	if err := op.extractArchive(ctx, input, key, fileHeader, config, temp); err != nil {
+		os.RemoveAll(temp)
+		fmt.Println("Partial output removed")
+		return err
+	}
+
+	if err := os.Rename(temp, config.OutputPath); err != nil {
+		os.RemoveAll(temp)
+		return fmt.Errorf("failed to move restored directory into place: %w", err)
+	}
+
+	if err := op.handleCleanup(config.InputPath, false, nil); err != nil {
+		return err
+	}
+
+	fmt.Printf("Directory %s restored from %s\n", config.OutputPath, config.InputPath)
+	return nil
+}
+
+func (op *Operations) extractArchive(ctx context.Context, input *os.File, key []byte, fileHeader header.Header, config OperationConfig, dest string) error {
+	processor, err := newDecryptionStream(key, fileHeader, config)
+	if err != nil {
+		return err
+	}
+
+	reader, writer := io.Pipe()
+	decrypted := make(chan error, 1)
+	go func() {
+		err := processor.ProcessContext(ctx, input, writer, int64(fileHeader.OriginalSize.Value))
+		writer.CloseWithError(err)
+		decrypted <- err
+	}()
+
+	err = archive.Extract(reader, dest)
+	if err == nil {
+		// Let the pipeline authenticate whatever follows the archive
+		_, err = io.Copy(io.Discard, reader)
+	}
+	reader.CloseWithError(err)
+
+	// Reading fails with the decryption error when that came first
+	decryptErr := <-decrypted
+	if decryptErr != nil && (err == nil || errors.Is(err, decryptErr)) {
+		return fmt.Errorf("decryption failed: %w", decryptErr)
+	}
+	if err != nil {
+		return fmt.Errorf("restoring directory failed: %w", err)
+	}
+	return nil
+}
@@ -2,7 +2,9 @@ package core
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
 
 	"github.com/hambosto/go-encryption/pkg/trash"
 )
@@ -28,6 +30,10 @@ func NewFileManager(overwritePasses int) *FileManager {
 }
 
 func (fm *FileManager) Delete(path string, deleteType DeleteType) error {
+	if info, err := os.Lstat(path); err == nil && info.IsDir() {
+		return fm.deleteDirectory(path, deleteType)
+	}
+
 	switch deleteType {
 	case DeleteTypeNormal:
 		return os.Remove(path)
@@ -38,6 +44,25 @@ func (fm *FileManager) Delete(path string, deleteType DeleteType) error {
 	}
 }
 
+// deleteDirectory removes a directory tree, overwriting its regular files
+// first when deleting securely.
+func (fm *FileManager) deleteDirectory(path string, deleteType DeleteType) error {
+	if deleteType == DeleteTypeSecure {
+		err := filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return err
+			}
+			return trash.SecureDelete(file, fm.overwritePasses)
+		})
+		if err != nil {
+			return err
+		}
+	} else if deleteType != DeleteTypeNormal {
+		return fmt.Errorf("invalid delete type: %s", deleteType)
+	}
+	return os.RemoveAll(path)
+}
+
 func (fm *FileManager) CreateOutput(path string) (*os.File, error) {
 	output, err := os.Create(path)
 	if err != nil {
@@ -53,7 +78,7 @@ func (fm *FileManager) Validate(path string, shouldExist bool) error {
 		if os.IsNotExist(err) {
 			return fmt.Errorf("file does not exist: %s", path)
 		}
-		if fileInfo.Size() == 0 {
+		if !fileInfo.IsDir() && fileInfo.Size() == 0 {
 			return fmt.Errorf("file is empty: %s", path)
 		}
 	} else {
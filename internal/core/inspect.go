@@ -0,0 +1,292 @@
+package core
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hambosto/go-encryption/internal/container"
+	"github.com/hambosto/go-encryption/internal/encoding"
+	"github.com/hambosto/go-encryption/internal/header"
+)
+
+type InspectHeader struct {
+	Magic         string `json:"magic"`
+	Version       uint16 `json:"version"`
+	Salt          string `json:"salt"`
+	OriginalSize  uint64 `json:"original_size"`
+	Streamed      bool   `json:"streamed,omitempty"`
+	AesNonce      string `json:"aes_nonce"`
+	ChaCha20Nonce string `json:"chacha20_nonce"`
+	StripeWidth   uint16 `json:"stripe_width"`
+	ChunkSize     uint32 `json:"chunk_size"`
+	EncodedSize   int    `json:"encoded_size"`
+	Primary       string `json:"primary"`
+	Backup        string `json:"backup"`
+}
+
+// InspectParameters are the algorithms a file was written with. The header
+// does not record them; they are fixed by its version.
+type InspectParameters struct {
+	Version      uint16   `json:"format_version"`
+	KDF          string   `json:"kdf"`
+	MemoryMB     uint32   `json:"kdf_memory_mb"`
+	Iterations   uint32   `json:"kdf_iterations"`
+	Parallelism  uint8    `json:"kdf_parallelism"`
+	KeyBytes     uint32   `json:"key_bytes"`
+	Ciphers      []string `json:"ciphers"`
+	Compression  string   `json:"compression"`
+	DataShards   int      `json:"data_shards"`
+	ParityShards int      `json:"parity_shards"`
+}
+
+type InspectLayout struct {
+	Layout       string `json:"layout"`
+	Chunks       uint32 `json:"chunks"`
+	Records      int    `json:"records"`
+	BodyBytes    int64  `json:"body_bytes"`
+	ChunkBytes   int64  `json:"chunk_bytes"`
+	MinChunk     int    `json:"min_chunk"`
+	MaxChunk     int    `json:"max_chunk"`
+	Index        bool   `json:"index"`
+	TrailerBytes int64  `json:"trailer_bytes"`
+}
+
+type InspectResult struct {
+	Path       string             `json:"path"`
+	FileSize   int64              `json:"file_size"`
+	Header     *InspectHeader     `json:"header,omitempty"`
+	Parameters *InspectParameters `json:"parameters,omitempty"`
+	Layout     *InspectLayout     `json:"layout,omitempty"`
+	Error      string             `json:"error,omitempty"`
+}
+
+type InspectReport struct {
+	Files []InspectResult `json:"files"`
+}
+
+// Failed reports whether any file could not be inspected in full.
+func (r InspectReport) Failed() bool {
+	for _, file := range r.Files {
+		if file.Error != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (r InspectReport) WriteText(w io.Writer) error {
+	var b strings.Builder
+
+	for i, file := range r.Files {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s (%d bytes)\n", file.Path, file.FileSize)
+
+		if h := file.Header; h != nil {
+			size := fmt.Sprint(h.OriginalSize)
+			if h.Streamed {
+				size += " (streamed, recorded in the footer)"
+			}
+			fmt.Fprintf(&b, "  header:\n")
+			fmt.Fprintf(&b, "    magic:          %s\n", h.Magic)
+			fmt.Fprintf(&b, "    version:        %d\n", h.Version)
+			fmt.Fprintf(&b, "    salt:           %s\n", h.Salt)
+			fmt.Fprintf(&b, "    original size:  %s\n", size)
+			fmt.Fprintf(&b, "    aes nonce:      %s\n", h.AesNonce)
+			fmt.Fprintf(&b, "    chacha20 nonce: %s\n", h.ChaCha20Nonce)
+			fmt.Fprintf(&b, "    stripe width:   %d\n", h.StripeWidth)
+			fmt.Fprintf(&b, "    chunk size:     %d\n", h.ChunkSize)
+			fmt.Fprintf(&b, "    copies:         primary %s, backup %s, %d bytes each\n", h.Primary, h.Backup, h.EncodedSize)
+		}
+
+		if p := file.Parameters; p != nil {
+			fmt.Fprintf(&b, "  parameters of format version %d:\n", p.Version)
+			fmt.Fprintf(&b, "    kdf:            %s, %d MB, %d iterations, %d threads, %d-byte key\n", p.KDF, p.MemoryMB, p.Iterations, p.Parallelism, p.KeyBytes)
+			fmt.Fprintf(&b, "    ciphers:        %s\n", strings.Join(p.Ciphers, " + "))
+			fmt.Fprintf(&b, "    compression:    %s\n", p.Compression)
+			fmt.Fprintf(&b, "    reed-solomon:   %d data + %d parity shards\n", p.DataShards, p.ParityShards)
+		}
+
+		if l := file.Layout; l != nil {
+			fmt.Fprintf(&b, "  body:\n")
+			fmt.Fprintf(&b, "    layout:         %s\n", l.Layout)
+			fmt.Fprintf(&b, "    chunks:         %d in %d records\n", l.Chunks, l.Records)
+			fmt.Fprintf(&b, "    encoded bytes:  %d (%d of chunk data)\n", l.BodyBytes, l.ChunkBytes)
+			if l.Chunks > 0 {
+				fmt.Fprintf(&b, "    chunk sizes:    %d to %d bytes\n", l.MinChunk, l.MaxChunk)
+			}
+			index := "none"
+			if l.Index {
+				index = "present"
+			}
+			fmt.Fprintf(&b, "    index:          %s, %d-byte trailer\n", index, l.TrailerBytes)
+		}
+
+		if file.Error != "" {
+			fmt.Fprintf(&b, "  error: %s\n", file.Error)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (r InspectReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// Inspect describes the header and body layout of encrypted files without
+// the password. Nothing is decrypted, so the chunks themselves are not
+// authenticated; use Scrub to check them for damage.
+func Inspect(paths []string) (InspectReport, error) {
+	var report InspectReport
+
+	rs, err := encoding.NewReedSolomon(encoding.DefaultConfig())
+	if err != nil {
+		return report, fmt.Errorf("failed to create Reed-Solomon encoder: %w", err)
+	}
+
+	for _, path := range paths {
+		report.Files = append(report.Files, inspectFile(path, rs))
+	}
+	return report, nil
+}
+
+func inspectFile(path string, rs *encoding.ReedSolomon) InspectResult {
+	result := InspectResult{Path: path}
+
+	file, err := os.Open(path)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.FileSize = info.Size()
+
+	reader := header.NewHeaderReader(header.NewBinaryHeaderIO())
+	fileHeader, err := reader.Read(file)
+	if err != nil {
+		result.Error = fmt.Sprintf("header reading failed: %v", err)
+		return result
+	}
+	primary := reader.PrimaryStatus()
+	if reader.UsedBackup() {
+		primary = header.CopyUnreadable
+	}
+
+	// Read for the status of the footer, and the size of a streamed file
+	footer, footerErr := reader.ReadBackup(file)
+	result.Header = &InspectHeader{
+		Magic:         string(fileHeader.Magic.Value),
+		Version:       fileHeader.Version.Value,
+		Salt:          hex.EncodeToString(fileHeader.Salt.Value),
+		OriginalSize:  fileHeader.OriginalSize.Value,
+		AesNonce:      hex.EncodeToString(fileHeader.AesNonce.Value),
+		ChaCha20Nonce: hex.EncodeToString(fileHeader.ChaCha20Nonce.Value),
+		StripeWidth:   fileHeader.StripeWidth.Value,
+		ChunkSize:     fileHeader.ChunkSize.Value,
+		EncodedSize:   header.EncodedSize(),
+		Primary:       primary.String(),
+		Backup:        reader.BackupStatus().String(),
+	}
+	if fileHeader.OriginalSize.Value == header.UnknownSize {
+		result.Header.Streamed = true
+		result.Header.OriginalSize = 0
+		if footerErr == nil && footer.OriginalSize.Value != header.UnknownSize {
+			result.Header.OriginalSize = footer.OriginalSize.Value
+		}
+	}
+
+	if params, ok := formatParameters[fileHeader.Version.Value]; ok {
+		result.Parameters = &params
+	}
+
+	layout, err := inspectBody(file, result.FileSize, fileHeader, rs.TotalShards())
+	result.Layout = layout
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// formatParameters holds the algorithms of every format version. They are
+// written out rather than taken from the current defaults, which a later
+// version may change without affecting files already written.
+var formatParameters = map[uint16]InspectParameters{
+	2: {
+		Version:      2,
+		KDF:          "argon2id",
+		MemoryMB:     64,
+		Iterations:   4,
+		Parallelism:  4,
+		KeyBytes:     64,
+		Ciphers:      []string{"AES-256-GCM", "ChaCha20-Poly1305"},
+		Compression:  "zlib",
+		DataShards:   4,
+		ParityShards: 10,
+	},
+}
+
+// inspectBody walks the length prefixes of the body, then looks for the
+// chunk index between the end-of-body marker and the footer.
+func inspectBody(file *os.File, fileSize int64, fileHeader header.Header, totalShards int) (*InspectLayout, error) {
+	stripeWidth := int(fileHeader.StripeWidth.Value)
+	layout := &InspectLayout{Layout: "contiguous"}
+	if stripeWidth > 1 {
+		layout.Layout = "interleaved"
+	}
+
+	if _, err := file.Seek(bodyStart(), io.SeekStart); err != nil {
+		return layout, fmt.Errorf("seek failed: %w", err)
+	}
+	offsets, err := container.ScanRecords(file, totalShards, stripeWidth, 0)
+	if err != nil {
+		return layout, fmt.Errorf("scanning records failed: %w", err)
+	}
+	layout.Records = len(offsets)
+
+	if _, err := file.Seek(bodyStart(), io.SeekStart); err != nil {
+		return layout, fmt.Errorf("seek failed: %w", err)
+	}
+	err = walkChunks(newBodyReader(file, fileHeader, totalShards), func(index uint32, chunk []byte) error {
+		layout.Chunks++
+		layout.ChunkBytes += int64(len(chunk))
+		if index == 0 || len(chunk) < layout.MinChunk {
+			layout.MinChunk = len(chunk)
+		}
+		layout.MaxChunk = max(layout.MaxChunk, len(chunk))
+		return nil
+	})
+	if err != nil {
+		return layout, err
+	}
+
+	// The container reader seeks back over anything it reads ahead, so this
+	// is just past the marker
+	end, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return layout, fmt.Errorf("seek failed: %w", err)
+	}
+	layout.BodyBytes = end - bodyStart()
+
+	trailerEnd := fileSize - int64(header.EncodedSize())
+	layout.TrailerBytes = trailerEnd - end
+	_, layout.Index, err = container.ReadLocator(file, trailerEnd)
+	if err != nil {
+		return layout, err
+	}
+	return layout, nil
+}
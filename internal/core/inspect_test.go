@@ -0,0 +1,29 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/hambosto/go-encryption/internal/encoding"
+	"github.com/hambosto/go-encryption/internal/header"
+	"github.com/hambosto/go-encryption/internal/kdf"
+)
+
+// Changing a default changes the format, so it needs a new version and a
+// new entry in formatParameters.
+func TestFormatParametersMatchCurrentDefaults(t *testing.T) {
+	params, ok := formatParameters[header.CurrentVersion]
+	if !ok {
+		t.Fatalf("no parameters for format version %d", header.CurrentVersion)
+	}
+
+	defaults := kdf.DefaultParameters()
+	if params.MemoryMB != defaults.MemoryMB || params.Iterations != defaults.Iterations ||
+		params.Parallelism != defaults.Parallelism || params.KeyBytes != defaults.KeyBytes {
+		t.Errorf("kdf parameters %+v do not match the defaults %+v", params, defaults)
+	}
+
+	config := encoding.DefaultConfig()
+	if params.DataShards != config.DataShards || params.ParityShards != config.ParityShards {
+		t.Errorf("%d+%d shards do not match the defaults %d+%d", params.DataShards, params.ParityShards, config.DataShards, config.ParityShards)
+	}
+}
@@ -0,0 +1,148 @@
+package core
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/hambosto/go-encryption/internal/container"
+	"github.com/hambosto/go-encryption/internal/encoding"
+	"github.com/hambosto/go-encryption/internal/header"
+)
+
+// handleMigrate converts a file written in format version 1 to the current
+// format and atomically replaces it. The ciphertext of every chunk is kept
+// and only re-encoded, so no password is needed. The migrated file has no
+// chunk index, which is encrypted; range extraction scans its body instead.
+func (op *Operations) handleMigrate(ctx context.Context, config OperationConfig) error {
+	input, inputInfo, err := op.fileManager.OpenInputFile(config.InputPath)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	reader := header.NewHeaderReader(header.NewBinaryHeaderIO())
+	if fileHeader, err := reader.Read(input); err == nil {
+		return fmt.Errorf("%s is already in format version %d", config.InputPath, fileHeader.Version.Value)
+	}
+
+	if !isLegacyFile(input, inputInfo.Size()) {
+		return fmt.Errorf("%s is not a format version %d file", config.InputPath, header.LegacyVersion)
+	}
+	if _, err := input.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seek failed: %w", err)
+	}
+	fileHeader, err := reader.ReadLegacy(input)
+	if err != nil {
+		return fmt.Errorf("header reading failed: %w", err)
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(config.InputPath), filepath.Base(config.InputPath)+".migrate-*")
+	if err != nil {
+		return fmt.Errorf("failed to create migration file: %w", err)
+	}
+	defer os.Remove(temp.Name())
+	defer temp.Close()
+
+	fmt.Printf("Migrating %s...\n", config.InputPath)
+	if err := migrateBody(ctx, input, temp, fileHeader); err != nil {
+		return err
+	}
+
+	if err := op.replaceFile(temp, config.InputPath, inputInfo.Mode()); err != nil {
+		return err
+	}
+	fmt.Printf("File %s migrated to format version %d\n", config.InputPath, fileHeader.Version.Value)
+	return nil
+}
+
+// migrateBody writes both header copies and re-encodes every chunk of the
+// version 1 body, the input being positioned just past its header.
+func migrateBody(ctx context.Context, input io.Reader, output io.Writer, fileHeader header.Header) error {
+	rs, err := encoding.NewReedSolomon(encoding.DefaultConfig())
+	if err != nil {
+		return fmt.Errorf("failed to create Reed-Solomon encoder: %w", err)
+	}
+
+	headers := header.NewHeaderWriter(header.NewBinaryHeaderIO())
+	if err := headers.Write(output, fileHeader); err != nil {
+		return fmt.Errorf("header writing failed: %w", err)
+	}
+
+	body := container.NewWriter(output, rs.TotalShards(), 0)
+	for index := 0; ; index++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		chunk, err := readLegacyChunk(input)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("reading chunk %d: %w", index, err)
+		}
+
+		sealed, err := rs.DecodeLegacy(chunk)
+		if err != nil {
+			return fmt.Errorf("chunk %d is damaged: %w", index, err)
+		}
+		encoded, err := rs.Encode(sealed)
+		if err != nil {
+			return fmt.Errorf("encoding chunk %d: %w", index, err)
+		}
+		if err := body.WriteChunk(encoded); err != nil {
+			return fmt.Errorf("writing chunk %d: %w", index, err)
+		}
+	}
+
+	if err := body.Close(); err != nil {
+		return fmt.Errorf("writing migrated file: %w", err)
+	}
+	if err := headers.Write(output, fileHeader); err != nil {
+		return fmt.Errorf("backup header writing failed: %w", err)
+	}
+	return nil
+}
+
+// readLegacyChunk reads a length-prefixed chunk of a version 1 body, which
+// ends at the end of the file. It returns io.EOF there.
+func readLegacyChunk(reader io.Reader) ([]byte, error) {
+	var buf [4]byte
+	if _, err := io.ReadFull(reader, buf[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("chunk size is truncated")
+		}
+		return nil, err
+	}
+
+	chunk := make([]byte, binary.BigEndian.Uint32(buf[:]))
+	if _, err := io.ReadFull(reader, chunk); err != nil {
+		return nil, fmt.Errorf("chunk data read failed: %w", err)
+	}
+	return chunk, nil
+}
+
+// isLegacyFile reports whether file has the layout of format version 1: a
+// bare header followed by as many length-prefixed chunks as its size calls
+// for, ending exactly at the end of the file.
+func isLegacyFile(file *os.File, size int64) bool {
+	position := int64(header.LegacySize())
+	var buf [header.OriginalSizeBytes]byte
+	if _, err := file.ReadAt(buf[:], header.SaltSize); err != nil {
+		return false
+	}
+	plaintext := binary.BigEndian.Uint64(buf[:])
+
+	var chunks uint64
+	for position < size {
+		if _, err := file.ReadAt(buf[:4], position); err != nil {
+			return false
+		}
+		position += 4 + int64(binary.BigEndian.Uint32(buf[:4]))
+		chunks++
+	}
+	return position == size && chunks == (plaintext+header.LegacyChunkSize-1)/header.LegacyChunkSize
+}
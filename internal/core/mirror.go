@@ -0,0 +1,478 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hambosto/go-encryption/internal/header"
+	"github.com/hambosto/go-encryption/internal/progress"
+)
+
+// mirrorManifestName is the file in the root of a mirror that records what
+// it holds. It is encrypted like any other file of the mirror.
+const mirrorManifestName = ".go-encryption-mirror"
+
+const mirrorManifestVersion = 1
+
+// MirrorConfig describes a mirror run: Source is the plaintext tree and
+// Target the encrypted one when mirroring, and the other way round when
+// restoring.
+type MirrorConfig struct {
+	Source   string
+	Target   string
+	Password string
+	// EncryptNames replaces file and directory names in the mirror by
+	// keyed hashes. It only takes effect when the mirror is created.
+	EncryptNames bool
+	StripeWidth  int
+	ChunkSize    int
+}
+
+// MirrorReport counts what a mirror run did with the files.
+type MirrorReport struct {
+	Written  int
+	UpToDate int
+	Removed  int
+	Skipped  []string
+}
+
+// mirrorManifest maps every plaintext path, relative and slash-separated,
+// to its counterpart in the mirror along with the metadata of the source.
+type mirrorManifest struct {
+	Version      int                        `json:"version"`
+	EncryptNames bool                       `json:"encrypt_names"`
+	Directories  map[string]mirrorDirectory `json:"directories"`
+	Files        map[string]mirrorFile      `json:"files"`
+}
+
+type mirrorDirectory struct {
+	Cipher  string      `json:"cipher"`
+	Mode    fs.FileMode `json:"mode"`
+	ModTime int64       `json:"mtime_ns"`
+}
+
+type mirrorFile struct {
+	Cipher  string      `json:"cipher"`
+	Mode    fs.FileMode `json:"mode"`
+	ModTime int64       `json:"mtime_ns"`
+	Size    int64       `json:"size"`
+}
+
+// mirrorKeys holds what every file of a mirror is encrypted with. All files
+// share the salt, so the password is stretched once per run, yet each one
+// can still be decrypted on its own like any other encrypted file.
+type mirrorKeys struct {
+	key  []byte
+	salt []byte
+}
+
+// MirrorEncrypt brings an encrypted mirror of a directory up to date. Files
+// whose size and modification time match the manifest are not encrypted
+// again, and files gone from the source are removed from the mirror.
+func (op *Operations) MirrorEncrypt(ctx context.Context, config MirrorConfig) (report MirrorReport, err error) {
+	if info, err := os.Stat(config.Source); err != nil || !info.IsDir() {
+		return report, fmt.Errorf("source %s is not a directory", config.Source)
+	}
+	if within(config.Target, config.Source) {
+		return report, fmt.Errorf("mirror %s cannot lie within its source %s", config.Target, config.Source)
+	}
+	if err := os.MkdirAll(config.Target, 0o755); err != nil {
+		return report, fmt.Errorf("failed to create mirror: %w", err)
+	}
+
+	keys, manifest, err := op.openMirror(ctx, config.Target, config)
+	if err != nil {
+		return report, err
+	}
+
+	// Whatever was done is recorded, even if the run stops early
+	defer func() {
+		if saveErr := op.saveManifest(ctx, config.Target, keys, manifest, config); saveErr != nil && err == nil {
+			err = saveErr
+		}
+	}()
+
+	seenFiles := make(map[string]bool)
+	seenDirectories := make(map[string]bool)
+	err = filepath.WalkDir(config.Source, func(source string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(config.Source, source)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+
+		switch {
+		case d.IsDir():
+			if name == "." {
+				return nil
+			}
+			entry := mirrorDirectory{Cipher: manifest.cipherName(keys, name), Mode: info.Mode().Perm(), ModTime: info.ModTime().UnixNano()}
+			if err := os.MkdirAll(filepath.Join(config.Target, filepath.FromSlash(entry.Cipher)), 0o755); err != nil {
+				return err
+			}
+			manifest.Directories[name] = entry
+			seenDirectories[name] = true
+		case info.Mode().IsRegular():
+			seenFiles[name] = true
+			written, err := op.mirrorFile(ctx, config, keys, manifest, name, source, info)
+			if err != nil {
+				return fmt.Errorf("%s: %w", source, err)
+			}
+			if written {
+				report.Written++
+			} else {
+				report.UpToDate++
+			}
+		default:
+			report.Skipped = append(report.Skipped, source)
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	report.Removed = manifest.prune(config.Target, seenFiles, seenDirectories)
+	return report, nil
+}
+
+func (op *Operations) mirrorFile(ctx context.Context, config MirrorConfig, keys mirrorKeys, manifest *mirrorManifest, name string, source string, info fs.FileInfo) (bool, error) {
+	entry := mirrorFile{
+		Cipher:  manifest.cipherName(keys, name) + encExtension,
+		Mode:    info.Mode().Perm(),
+		ModTime: info.ModTime().UnixNano(),
+		Size:    info.Size(),
+	}
+	target := filepath.Join(config.Target, filepath.FromSlash(entry.Cipher))
+
+	if recorded, ok := manifest.Files[name]; ok && recorded.Size == entry.Size && recorded.ModTime == entry.ModTime && recorded.Cipher == entry.Cipher {
+		if _, err := os.Stat(target); err == nil {
+			manifest.Files[name] = entry
+			return false, nil
+		}
+	}
+
+	input, err := os.Open(source)
+	if err != nil {
+		return false, err
+	}
+	defer input.Close()
+
+	fileConfig := OperationConfig{StripeWidth: config.StripeWidth, ChunkSize: config.ChunkSize, Progress: progress.Silent{}}
+	err = writeAtomically(target, func(output *os.File) error {
+		return op.performEncryption(ctx, input, output, entry.Size, keys.key, keys.salt, fileConfig, checkpointState{})
+	})
+	if err != nil {
+		return false, err
+	}
+
+	fmt.Printf("Encrypted %s\n", name)
+	manifest.Files[name] = entry
+	return true, nil
+}
+
+// prune removes from the mirror what is no longer in the source and
+// returns the number of files removed.
+func (m *mirrorManifest) prune(target string, files map[string]bool, directories map[string]bool) int {
+	removed := 0
+	for name, entry := range m.Files {
+		if files[name] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(target, filepath.FromSlash(entry.Cipher))); err == nil || errors.Is(err, fs.ErrNotExist) {
+			delete(m.Files, name)
+			removed++
+		}
+	}
+
+	// Deepest first; a directory still holding anything is kept
+	var names []string
+	for name := range m.Directories {
+		if !directories[name] {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	slices.Reverse(names)
+	for _, name := range names {
+		if err := os.Remove(filepath.Join(target, filepath.FromSlash(m.Directories[name].Cipher))); err == nil || errors.Is(err, fs.ErrNotExist) {
+			delete(m.Directories, name)
+		}
+	}
+	return removed
+}
+
+// MirrorRestore decrypts a mirror back into a plaintext tree. Files already
+// present with the recorded size and modification time are left alone.
+func (op *Operations) MirrorRestore(ctx context.Context, config MirrorConfig) (MirrorReport, error) {
+	var report MirrorReport
+	if _, err := os.Stat(filepath.Join(config.Source, mirrorManifestName)); err != nil {
+		return report, fmt.Errorf("%s is not an encrypted mirror: %w", config.Source, err)
+	}
+
+	keys, manifest, err := op.openMirror(ctx, config.Source, config)
+	if err != nil {
+		return report, err
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(manifest.Directories)) {
+		if err := checkMirrorPaths(name, manifest.Directories[name].Cipher); err != nil {
+			return report, err
+		}
+		if err := os.MkdirAll(filepath.Join(config.Target, filepath.FromSlash(name)), 0o700); err != nil {
+			return report, err
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(manifest.Files)) {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		entry := manifest.Files[name]
+		if err := checkMirrorPaths(name, entry.Cipher); err != nil {
+			return report, err
+		}
+		target := filepath.Join(config.Target, filepath.FromSlash(name))
+		modTime := time.Unix(0, entry.ModTime)
+
+		if info, err := os.Stat(target); err == nil && info.Size() == entry.Size && info.ModTime().Equal(modTime) {
+			report.UpToDate++
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+			return report, err
+		}
+		err := writeAtomically(target, func(output *os.File) error {
+			return decryptWithKey(ctx, filepath.Join(config.Source, filepath.FromSlash(entry.Cipher)), output, keys.key)
+		})
+		if err != nil {
+			return report, fmt.Errorf("%s: %w", name, err)
+		}
+		if err := os.Chmod(target, entry.Mode); err != nil {
+			return report, err
+		}
+		if err := os.Chtimes(target, modTime, modTime); err != nil {
+			return report, err
+		}
+
+		fmt.Printf("Restored %s\n", name)
+		report.Written++
+	}
+
+	// Deepest first, so that restoring a directory's time is not undone by
+	// changes inside it
+	names := slices.Sorted(maps.Keys(manifest.Directories))
+	slices.Reverse(names)
+	for _, name := range names {
+		entry := manifest.Directories[name]
+		target := filepath.Join(config.Target, filepath.FromSlash(name))
+		modTime := time.Unix(0, entry.ModTime)
+		if err := os.Chmod(target, entry.Mode); err != nil {
+			return report, err
+		}
+		if err := os.Chtimes(target, modTime, modTime); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// openMirror derives the key of the mirror in root from the salt of its
+// manifest and decrypts the manifest, which also checks the password. A
+// new mirror gets a fresh salt and an empty manifest.
+func (op *Operations) openMirror(ctx context.Context, root string, config MirrorConfig) (mirrorKeys, *mirrorManifest, error) {
+	manifestPath := filepath.Join(root, mirrorManifestName)
+
+	password := config.Password
+	if password == "" {
+		var err error
+		if password, err = op.userPrompt.GetPassword(); err != nil {
+			return mirrorKeys{}, nil, fmt.Errorf("password prompt failed: %w", err)
+		}
+	}
+
+	file, err := os.Open(manifestPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		if entries, _ := os.ReadDir(root); len(entries) > 0 {
+			return mirrorKeys{}, nil, fmt.Errorf("%s is not empty and holds no mirror", root)
+		}
+		key, salt, err := op.deriveKey(password)
+		if err != nil {
+			return mirrorKeys{}, nil, err
+		}
+		manifest := &mirrorManifest{
+			Version:      mirrorManifestVersion,
+			EncryptNames: config.EncryptNames,
+			Directories:  make(map[string]mirrorDirectory),
+			Files:        make(map[string]mirrorFile),
+		}
+		return mirrorKeys{key: key, salt: salt}, manifest, nil
+	}
+	if err != nil {
+		return mirrorKeys{}, nil, fmt.Errorf("failed to open mirror manifest: %w", err)
+	}
+	defer file.Close()
+
+	fileHeader, err := header.NewHeaderReader(header.NewBinaryHeaderIO()).Read(file)
+	if err != nil {
+		return mirrorKeys{}, nil, fmt.Errorf("mirror manifest header reading failed: %w", err)
+	}
+	keys := mirrorKeys{salt: fileHeader.Salt.Value}
+	if keys.key, err = deriveKeyWithSalt(password, keys.salt); err != nil {
+		return mirrorKeys{}, nil, err
+	}
+
+	var plain bytes.Buffer
+	if err := decryptWithKey(ctx, manifestPath, &plain, keys.key); err != nil {
+		return mirrorKeys{}, nil, fmt.Errorf("mirror manifest could not be decrypted, the password may be wrong: %w", err)
+	}
+
+	manifest := &mirrorManifest{}
+	if err := json.Unmarshal(plain.Bytes(), manifest); err != nil {
+		return mirrorKeys{}, nil, fmt.Errorf("mirror manifest is malformed: %w", err)
+	}
+	if manifest.Version != mirrorManifestVersion {
+		return mirrorKeys{}, nil, fmt.Errorf("unsupported mirror manifest version %d", manifest.Version)
+	}
+	if manifest.Directories == nil {
+		manifest.Directories = make(map[string]mirrorDirectory)
+	}
+	if manifest.Files == nil {
+		manifest.Files = make(map[string]mirrorFile)
+	}
+	return keys, manifest, nil
+}
+
+func (op *Operations) saveManifest(ctx context.Context, target string, keys mirrorKeys, manifest *mirrorManifest, config MirrorConfig) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to encode mirror manifest: %w", err)
+	}
+
+	// Saved even when ctx was cancelled, so that the next run can skip the
+	// files already done
+	fileConfig := OperationConfig{StripeWidth: config.StripeWidth, Progress: progress.Silent{}}
+	return writeAtomically(filepath.Join(target, mirrorManifestName), func(output *os.File) error {
+		return op.performEncryption(context.WithoutCancel(ctx), bytes.NewReader(data), output, int64(len(data)), keys.key, keys.salt, fileConfig, checkpointState{})
+	})
+}
+
+// cipherName returns the path in the mirror for a plaintext path. With
+// encrypted names every component is replaced by a keyed hash of the path
+// up to it, so equal names in different directories differ as well.
+func (m *mirrorManifest) cipherName(keys mirrorKeys, name string) string {
+	if !m.EncryptNames {
+		return name
+	}
+
+	mac := hmac.New(sha256.New, keys.key)
+	mac.Write([]byte("go-encryption mirror names"))
+	nameKey := mac.Sum(nil)
+
+	parts := strings.Split(name, "/")
+	hashed := make([]string, len(parts))
+	for i := range parts {
+		mac := hmac.New(sha256.New, nameKey)
+		mac.Write([]byte(path.Join(parts[:i+1]...)))
+		hashed[i] = strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(mac.Sum(nil)[:20]))
+	}
+	return strings.Join(hashed, "/")
+}
+
+// checkMirrorPaths rejects manifest entries that would lead outside the
+// mirror or the restored tree.
+func checkMirrorPaths(names ...string) error {
+	for _, name := range names {
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return fmt.Errorf("unsafe path %q in mirror manifest", name)
+		}
+	}
+	return nil
+}
+
+// decryptWithKey decrypts the file at path to w with an already derived key.
+func decryptWithKey(ctx context.Context, path string, w io.Writer, key []byte) error {
+	input, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	fileHeader, err := header.NewHeaderReader(header.NewBinaryHeaderIO()).Read(input)
+	if err != nil {
+		return fmt.Errorf("header reading failed: %w", err)
+	}
+	if fileHeader, err = resolveSize(input, fileHeader); err != nil {
+		return err
+	}
+
+	processor, err := newDecryptionStream(key, fileHeader, OperationConfig{Progress: progress.Silent{}})
+	if err != nil {
+		return err
+	}
+	if err := processor.ProcessContext(ctx, input, w, int64(fileHeader.OriginalSize.Value)); err != nil {
+		return fmt.Errorf("decryption failed: %w", err)
+	}
+	return nil
+}
+
+// writeAtomically lets write fill a temporary file next to path and renames
+// it into place only if that succeeds.
+func writeAtomically(path string, write func(*os.File) error) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".partial-*")
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+
+	err = write(temp)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+	}
+	return err
+}
+
+// within reports whether path is dir or lies beneath it.
+func within(path string, dir string) bool {
+	absPath, err1 := filepath.Abs(path)
+	absDir, err2 := filepath.Abs(dir)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	return err == nil && filepath.IsLocal(rel)
+}
@@ -1,11 +1,19 @@
 package core
 
 import (
+	"context"
+	"crypto/sha256"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 
+	"github.com/hambosto/go-encryption/internal/container"
+	"github.com/hambosto/go-encryption/internal/encoding"
 	"github.com/hambosto/go-encryption/internal/header"
 	"github.com/hambosto/go-encryption/internal/kdf"
+	"github.com/hambosto/go-encryption/internal/processor"
+	"github.com/hambosto/go-encryption/internal/progress"
 	"github.com/hambosto/go-encryption/internal/worker"
 )
 
@@ -14,16 +22,37 @@ type OperationType string
 const (
 	OperationEncrypt OperationType = "encryption"
 	OperationDecrypt OperationType = "decryption"
+	OperationRepair  OperationType = "repair"
+	OperationVerify  OperationType = "verification"
+	OperationMigrate OperationType = "migration"
 	Encrypt          OperationType = "Encrypt"
 	Decrypt          OperationType = "Decrypt"
+	Repair           OperationType = "Repair"
+	Verify           OperationType = "Verify"
+	Migrate          OperationType = "Migrate"
 	encExtension                   = ".enc"
 )
 
 type OperationConfig struct {
-	InputPath  string
-	OutputPath string
-	Password   string
-	Operation  OperationType
+	InputPath   string
+	OutputPath  string
+	Password    string
+	Operation   OperationType
+	StripeWidth int
+	ChunkSize   int
+	Salvage     worker.SalvageMode
+	Progress    progress.Reporter
+	// Workers caps the chunks processed in parallel; zero uses every CPU.
+	Workers int
+
+	// SkipVerify deletes the original after encryption without first
+	// decrypting the new file again to check that it gives the original back.
+	SkipVerify bool
+
+	// Checkpoint periodically records how far the operation got, keeping
+	// the partial output on failure so that Resume can continue it.
+	Checkpoint bool
+	Resume     bool
 }
 
 type Operations struct {
@@ -39,25 +68,85 @@ func NewOperation(fileManager FileManagerInterface, userPrompt PromptInterface)
 }
 
 func (op *Operations) Process(config OperationConfig) error {
+	return op.ProcessContext(context.Background(), config)
+}
+
+// ProcessContext runs the operation until it completes or ctx is cancelled.
+// On cancellation the input is left untouched and partial output is
+// removed, unless the caller asked for checkpoints and one was written:
+// then the output is kept so that the run can be resumed.
+func (op *Operations) ProcessContext(ctx context.Context, config OperationConfig) error {
+	if config.Operation == OperationRepair {
+		if err := op.validatePath(config.InputPath, true); err != nil {
+			return fmt.Errorf("input validation failed: %w", err)
+		}
+		return op.handleRepair(ctx, config)
+	}
+
+	if config.Operation == OperationVerify {
+		if err := op.validatePath(config.InputPath, true); err != nil {
+			return fmt.Errorf("input validation failed: %w", err)
+		}
+		return op.handleVerify(ctx, config)
+	}
+
+	if config.Operation == OperationMigrate {
+		if err := op.validatePath(config.InputPath, true); err != nil {
+			return fmt.Errorf("input validation failed: %w", err)
+		}
+		return op.handleMigrate(ctx, config)
+	}
+
+	if config.Operation == OperationEncrypt && isDirectory(config.InputPath) {
+		return op.handleDirectoryEncryption(ctx, config)
+	}
+
 	if err := op.validateOperation(config); err != nil {
 		return err
 	}
 
 	switch config.Operation {
 	case OperationEncrypt:
-		return op.handleEncryption(config)
+		return op.handleEncryption(ctx, config)
 	case OperationDecrypt:
-		return op.handleDecryption(config)
+		return op.handleDecryption(ctx, config)
 	default:
 		return fmt.Errorf("unsupported operation: %s", config.Operation)
 	}
 }
 
 func (op *Operations) validateOperation(config OperationConfig) error {
+	if config.StripeWidth < 0 || config.StripeWidth > header.MaxStripeWidth {
+		return fmt.Errorf("stripe width must be between 0 and %d", header.MaxStripeWidth)
+	}
+
+	if config.ChunkSize != 0 && (config.ChunkSize < header.MinChunkSize || config.ChunkSize > header.MaxChunkSize) {
+		return fmt.Errorf("chunk size must be between %d and %d bytes", header.MinChunkSize, header.MaxChunkSize)
+	}
+
+	if config.Salvage != worker.SalvageOff && config.Operation != OperationDecrypt {
+		return fmt.Errorf("salvage mode only applies to decryption")
+	}
+
+	if (config.Checkpoint || config.Resume) && config.Salvage != worker.SalvageOff {
+		return fmt.Errorf("checkpoints cannot be combined with salvage mode")
+	}
+
 	if err := op.validatePath(config.InputPath, true); err != nil {
 		return fmt.Errorf("input validation failed: %w", err)
 	}
 
+	if filepath.Clean(config.InputPath) == filepath.Clean(config.OutputPath) {
+		return fmt.Errorf("output %s would overwrite the input", config.OutputPath)
+	}
+
+	if config.Resume {
+		if !CheckpointExists(config.OutputPath) {
+			return fmt.Errorf("no checkpoint found for %s", config.OutputPath)
+		}
+		return nil
+	}
+
 	if err := op.validatePath(config.OutputPath, false); err != nil {
 		overwrite, promptErr := op.userPrompt.ConfirmOverwrite(config.OutputPath)
 		if promptErr != nil {
@@ -94,7 +183,51 @@ func (op *Operations) deriveKey(password string) ([]byte, []byte, error) {
 	return key, salt, nil
 }
 
-func (op *Operations) handleCleanup(path string, isEncryption bool) error {
+func deriveKeyWithSalt(password string, salt []byte) ([]byte, error) {
+	kdf, err := kdf.NewDeriver(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create KDF: %v", err)
+	}
+
+	key, err := kdf.DeriveKey([]byte(password), salt)
+	if err != nil {
+		return nil, fmt.Errorf("key derivation failed: %w", err)
+	}
+
+	return key, nil
+}
+
+// openOutput creates a fresh output, discarding any stale checkpoint, or
+// reopens the partial output of the run being resumed.
+func (op *Operations) openOutput(config OperationConfig) (*os.File, error) {
+	if !config.Resume {
+		os.Remove(checkpointPath(config.OutputPath))
+		return op.fileManager.CreateOutput(config.OutputPath)
+	}
+
+	output, err := os.OpenFile(config.OutputPath, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open partial output: %w", err)
+	}
+	return output, nil
+}
+
+// abandonOutput removes the output of a failed run unless a checkpoint
+// allows it to be resumed.
+func (op *Operations) abandonOutput(config OperationConfig, output *os.File) {
+	output.Close()
+	if (config.Checkpoint || config.Resume) && CheckpointExists(config.OutputPath) {
+		fmt.Printf("Partial output kept in %s, resume to continue\n", config.OutputPath)
+		return
+	}
+	os.Remove(config.OutputPath)
+	fmt.Println("Partial output removed")
+}
+
+// handleCleanup offers to delete the input once the operation succeeded. If
+// the user agrees and check is not nil, the input is only deleted once check
+// passes.
+func (op *Operations) handleCleanup(path string, isEncryption bool, check func() error) error {
 	shouldDelete, deleteType, err := op.userPrompt.ConfirmDelete(
 		path,
 		fmt.Sprintf("Delete %s file", map[bool]string{true: "original", false: "encrypted"}[isEncryption]),
@@ -104,6 +237,11 @@ func (op *Operations) handleCleanup(path string, isEncryption bool) error {
 	}
 
 	if shouldDelete {
+		if check != nil {
+			if err := check(); err != nil {
+				return fmt.Errorf("%s was not deleted: %w", path, err)
+			}
+		}
 		if err := op.fileManager.Delete(path, deleteType); err != nil {
 			return fmt.Errorf("file deletion failed: %w", err)
 		}
@@ -112,13 +250,19 @@ func (op *Operations) handleCleanup(path string, isEncryption bool) error {
 	return nil
 }
 
-func (op *Operations) performEncryption(input *os.File, output *os.File, fileInfo os.FileInfo, key []byte, salt []byte) error {
+func (op *Operations) performEncryption(ctx context.Context, input io.Reader, output *os.File, size int64, key []byte, salt []byte, config OperationConfig, state checkpointState) error {
 	processor, err := worker.NewWorkerStream(key, true)
 	if err != nil {
 		return fmt.Errorf("encryption processor creation failed: %w", err)
 	}
 
-	headerBuilder, err := header.NewHeaderBuilder().WithSalt(salt).WithOriginalSize(uint64(fileInfo.Size())).WithAesNonce(processor.GetAESNonce()).WithChaCha20Nonce(processor.GetChaCha20Nonce()).Build()
+	chunkSize := config.ChunkSize
+	if chunkSize == 0 {
+		chunkSize = worker.ChunkSizeFor(size)
+	}
+	processor.WithStripeWidth(config.StripeWidth).WithChunkSize(chunkSize).WithProgress(config.Progress).WithWorkerCount(config.Workers)
+
+	headerBuilder, err := header.NewHeaderBuilder().WithSalt(salt).WithOriginalSize(uint64(size)).WithAesNonce(processor.GetAESNonce()).WithChaCha20Nonce(processor.GetChaCha20Nonce()).WithStripeWidth(uint16(config.StripeWidth)).WithChunkSize(uint32(chunkSize)).Build()
 	if err != nil {
 		return fmt.Errorf("header building failed: %w", err)
 	}
@@ -127,18 +271,21 @@ func (op *Operations) performEncryption(input *os.File, output *os.File, fileInf
 		return fmt.Errorf("header writing failed: %w", err)
 	}
 
-	if err = processor.Process(input, output, fileInfo.Size()); err != nil {
-		return fmt.Errorf("encryption failed: %w", err)
-	}
-
-	return nil
+	return op.encryptBody(ctx, processor, input, output, size, headerBuilder, config, state, newOutputHash(bodyStart()))
 }
 
-func (op *Operations) performDecryption(input *os.File, output *os.File, key []byte, fileHeader header.Header) error {
-	processor, err := worker.NewWorkerStream(key, false)
+// resumeEncryption continues an interrupted encryption, reusing the header,
+// and with it the salt and nonces, already written to the partial output.
+func (op *Operations) resumeEncryption(ctx context.Context, input *os.File, output *os.File, fileInfo os.FileInfo, key []byte, fileHeader header.Header, config OperationConfig, state checkpointState) error {
+	if fileHeader.OriginalSize.Value != uint64(fileInfo.Size()) {
+		return fmt.Errorf("partial output was written for a different input")
+	}
+
+	processor, err := worker.NewWorkerStream(key, true)
 	if err != nil {
-		return fmt.Errorf("decryption processor creation failed: %w", err)
+		return fmt.Errorf("encryption processor creation failed: %w", err)
 	}
+	processor.WithStripeWidth(int(fileHeader.StripeWidth.Value)).WithChunkSize(int(fileHeader.ChunkSize.Value)).WithProgress(config.Progress).WithWorkerCount(config.Workers).WithResume(state.Progress)
 
 	if err := processor.SetAESNonce(fileHeader.AesNonce.Value); err != nil {
 		return fmt.Errorf("AES nonce setting failed: %w", err)
@@ -148,25 +295,76 @@ func (op *Operations) performDecryption(input *os.File, output *os.File, key []b
 		return fmt.Errorf("ChaCha20 nonce setting failed: %w", err)
 	}
 
-	if err := processor.Process(input, output, int64(fileHeader.OriginalSize.Value)); err != nil {
-		return fmt.Errorf("decryption failed: %w", err)
+	records, err := resumedRecords(output, fileHeader, state.Progress)
+	if err != nil {
+		return err
+	}
+	processor.WithResumedRecords(records)
+
+	hashed, err := seekToCheckpoint(input, 0, output, bodyStart(), state)
+	if err != nil {
+		return err
+	}
+
+	return op.encryptBody(ctx, processor, input, output, fileInfo.Size(), fileHeader, config, state, hashed)
+}
+
+func (op *Operations) encryptBody(ctx context.Context, processor *worker.WorkerStream, input io.Reader, output *os.File, size int64, fileHeader header.Header, config OperationConfig, state checkpointState, hashed *outputHash) error {
+	if config.Checkpoint || config.Resume {
+		processor.WithCheckpoints(checkpointInterval, checkpointer(config, output, state, hashed))
+	}
+
+	if err := processor.ProcessContext(ctx, input, output, size); err != nil {
+		return fmt.Errorf("encryption failed: %w", err)
+	}
+
+	if err := header.NewHeaderWriter(header.NewBinaryHeaderIO()).Write(output, fileHeader); err != nil {
+		return fmt.Errorf("backup header writing failed: %w", err)
 	}
 
 	return nil
 }
 
-func (op *Operations) handleEncryption(config OperationConfig) error {
+func (op *Operations) performDecryption(ctx context.Context, input *os.File, output *os.File, key []byte, fileHeader header.Header, config OperationConfig, state checkpointState) ([]worker.DamagedRange, error) {
+	processor, err := newDecryptionStream(key, fileHeader, config)
+	if err != nil {
+		return nil, err
+	}
+
+	hashed := newOutputHash(0)
+	if config.Resume {
+		processor.WithResume(state.Progress)
+		if hashed, err = seekToCheckpoint(input, bodyStart(), output, 0, state); err != nil {
+			return nil, err
+		}
+	}
+	if config.Checkpoint || config.Resume {
+		processor.WithCheckpoints(checkpointInterval, checkpointer(config, output, state, hashed))
+	}
+	if config.Salvage != worker.SalvageOff {
+		processor.WithRecordOffsets(salvageOffsets(input, key, fileHeader))
+	}
+
+	if err := processor.ProcessContext(ctx, input, output, int64(fileHeader.OriginalSize.Value)); err != nil {
+		return nil, fmt.Errorf("decryption failed: %w", err)
+	}
+
+	return processor.DamagedRanges(), nil
+}
+
+func (op *Operations) handleEncryption(ctx context.Context, config OperationConfig) error {
 	input, inputInfo, err := op.fileManager.OpenInputFile(config.InputPath)
 	if err != nil {
 		return err
 	}
 	defer input.Close()
 
-	output, err := op.fileManager.CreateOutput(config.OutputPath)
-	if err != nil {
-		return err
+	var state checkpointState
+	if config.Resume {
+		if state, err = loadCheckpoint(config, inputInfo); err != nil {
+			return err
+		}
 	}
-	defer output.Close()
 
 	password := config.Password
 	if password == "" {
@@ -176,20 +374,56 @@ func (op *Operations) handleEncryption(config OperationConfig) error {
 		}
 	}
 
-	key, salt, err := op.deriveKey(password)
+	output, err := op.openOutput(config)
 	if err != nil {
 		return err
 	}
+	defer output.Close()
 
-	fmt.Printf("Encrypting %s...\n", config.InputPath)
+	var key, salt []byte
+	var partialHeader header.Header
+	digest := sha256.New()
+	if config.Resume {
+		partialHeader, err = header.NewHeaderReader(header.NewBinaryHeaderIO()).Read(output)
+		if err != nil {
+			return fmt.Errorf("partial output header reading failed: %w", err)
+		}
+		if key, err = deriveKeyWithSalt(password, partialHeader.Salt.Value); err != nil {
+			return err
+		}
+		if err := state.verifyKey(key); err != nil {
+			return err
+		}
+	} else {
+		if key, salt, err = op.deriveKey(password); err != nil {
+			return err
+		}
+		state = newCheckpointState(config, inputInfo, key)
+	}
 
-	if err = op.performEncryption(input, output, inputInfo, key, salt); err != nil {
-		output.Close()
-		os.Remove(config.OutputPath)
+	if err := ctx.Err(); err != nil {
+		op.abandonOutput(config, output)
+		return err
+	}
+
+	if config.Resume {
+		fmt.Printf("Resuming encryption of %s at %d bytes...\n", config.InputPath, state.Progress.BytesDone)
+		err = op.resumeEncryption(ctx, input, output, inputInfo, key, partialHeader, config, state)
+	} else {
+		fmt.Printf("Encrypting %s...\n", config.InputPath)
+		err = op.performEncryption(ctx, io.TeeReader(input, digest), output, inputInfo.Size(), key, salt, config, state)
+	}
+	if err != nil {
+		op.abandonOutput(config, output)
 		return err
 	}
+	os.Remove(checkpointPath(config.OutputPath))
 
-	if err = op.handleCleanup(config.InputPath, true); err != nil {
+	// A resumed run did not see the start of the input
+	if config.Resume {
+		digest = nil
+	}
+	if err = op.handleCleanup(config.InputPath, true, op.roundTripCheck(ctx, config, key, digest)); err != nil {
 		return err
 	}
 
@@ -197,55 +431,264 @@ func (op *Operations) handleEncryption(config OperationConfig) error {
 	return nil
 }
 
-func (op *Operations) handleDecryption(config OperationConfig) error {
-	input, _, err := op.fileManager.OpenInputFile(config.InputPath)
+func (op *Operations) handleDecryption(ctx context.Context, config OperationConfig) error {
+	input, inputInfo, err := op.fileManager.OpenInputFile(config.InputPath)
 	if err != nil {
 		return err
 	}
 	defer input.Close()
 
+	var state checkpointState
+	if config.Resume {
+		if state, err = loadCheckpoint(config, inputInfo); err != nil {
+			return err
+		}
+	}
+
+	fileHeader, key, err := op.unlock(input, config)
+	if err != nil {
+		return err
+	}
+
+	if config.Resume {
+		if err := state.verifyKey(key); err != nil {
+			return err
+		}
+	} else {
+		state = newCheckpointState(config, inputInfo, key)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	directory, err := isArchive(input, key, fileHeader)
+	if err != nil {
+		return err
+	}
+	if directory {
+		return op.restoreDirectory(ctx, input, key, fileHeader, config)
+	}
+
+	// Salvage carries on past chunks that fail to decrypt, so a wrong
+	// password would otherwise only show once the whole output is written
+	if config.Salvage != worker.SalvageOff {
+		if err := checkSalvageKey(input, key, fileHeader); err != nil {
+			return err
+		}
+	}
+
+	output, err := op.openOutput(config)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+
+	if config.Resume {
+		fmt.Printf("Resuming decryption of %s at %d bytes...\n", config.InputPath, state.Progress.BytesDone)
+	} else {
+		fmt.Printf("Decrypting %s...\n", config.InputPath)
+	}
+
+	damaged, err := op.performDecryption(ctx, input, output, key, fileHeader, config, state)
+	if err != nil {
+		op.abandonOutput(config, output)
+		return err
+	}
+	os.Remove(checkpointPath(config.OutputPath))
+
+	if len(damaged) > 0 {
+		return op.finishSalvage(config, output, fileHeader, damaged)
+	}
+
+	if err = op.handleCleanup(config.InputPath, false, nil); err != nil {
+		return err
+	}
+
+	fmt.Printf("File %s decrypted successfully\n", config.OutputPath)
+	return nil
+}
+
+// unlock reads the header of an encrypted input and derives its key,
+// leaving the input positioned at the start of the body. The size a
+// streaming writer recorded in the footer is checked against the
+// authenticated copy in the chunk index.
+func (op *Operations) unlock(input *os.File, config OperationConfig) (header.Header, []byte, error) {
 	reader := header.NewHeaderReader(header.NewBinaryHeaderIO())
 	fileHeader, err := reader.Read(input)
 	if err != nil {
-		return fmt.Errorf("header reading failed: %w", err)
+		if info, statErr := input.Stat(); statErr == nil && isLegacyFile(input, info.Size()) {
+			return fileHeader, nil, fmt.Errorf("%s was written in format version %d, run Migrate to convert it", config.InputPath, header.LegacyVersion)
+		}
+		return fileHeader, nil, fmt.Errorf("header reading failed: %w", err)
+	}
+	warnHeaderDamage(reader)
+
+	streamed := fileHeader.OriginalSize.Value == header.UnknownSize
+	if fileHeader, err = resolveSize(input, fileHeader); err != nil {
+		return fileHeader, nil, err
 	}
 
 	password := config.Password
 	if password == "" {
 		password, err = op.userPrompt.GetPassword()
 		if err != nil {
-			return fmt.Errorf("password prompt failed: %w", err)
+			return fileHeader, nil, fmt.Errorf("password prompt failed: %w", err)
 		}
 	}
 
-	kdf, err := kdf.NewDeriver(nil)
+	key, err := deriveKeyWithSalt(password, fileHeader.Salt.Value)
 	if err != nil {
-		return fmt.Errorf("failed to create KDF: %v", err)
+		return fileHeader, nil, err
 	}
 
-	key, err := kdf.DeriveKey([]byte(password), fileHeader.Salt.Value)
+	if streamed {
+		if err := verifyStoredSize(input, fileHeader, key); err != nil {
+			return fileHeader, nil, err
+		}
+	}
+	return fileHeader, key, nil
+}
+
+func newDecryptionStream(key []byte, fileHeader header.Header, config OperationConfig) (*worker.WorkerStream, error) {
+	processor, err := worker.NewWorkerStream(key, false)
 	if err != nil {
-		return fmt.Errorf("key derivation failed: %w", err)
+		return nil, fmt.Errorf("decryption processor creation failed: %w", err)
 	}
+	processor.WithStripeWidth(int(fileHeader.StripeWidth.Value)).WithChunkSize(int(fileHeader.ChunkSize.Value)).WithSalvage(config.Salvage).WithProgress(config.Progress).WithWorkerCount(config.Workers)
+
+	if err := processor.SetAESNonce(fileHeader.AesNonce.Value); err != nil {
+		return nil, fmt.Errorf("AES nonce setting failed: %w", err)
+	}
+
+	if err := processor.SetChaCha20Nonce(fileHeader.ChaCha20Nonce.Value); err != nil {
+		return nil, fmt.Errorf("ChaCha20 nonce setting failed: %w", err)
+	}
+	return processor, nil
+}
 
-	output, err := op.fileManager.CreateOutput(config.OutputPath)
+// salvageOffsets reads the record offsets from the chunk index, which lets
+// salvage mode skip damaged framing exactly. Without a usable index it
+// returns nil and damaged records are found by scanning instead.
+func salvageOffsets(input *os.File, key []byte, fileHeader header.Header) []int64 {
+	info, err := input.Stat()
+	if err != nil {
+		return nil
+	}
+	chunks, err := newChunkDecryptor(key, fileHeader)
+	if err != nil {
+		return nil
+	}
+
+	offsets, size, err := readIndex(input, info.Size()-int64(header.EncodedSize()), fileHeader, chunks)
+	if err == nil && offsets != nil && size != int64(fileHeader.OriginalSize.Value) {
+		err = fmt.Errorf("it records %d bytes but the header %d", size, fileHeader.OriginalSize.Value)
+	}
+	if err != nil {
+		fmt.Printf("Warning: the chunk index is unusable (%v), damaged records will be found by scanning\n", err)
+		return nil
+	}
+	return offsets
+}
+
+// checkSalvageKey authenticates the chunk index with key or, without a
+// readable index, the first chunk whose shards are readable. Either one
+// failing to decrypt means the password is wrong, as its shards are known
+// to be intact. The input is left at the start of the body.
+func checkSalvageKey(input *os.File, key []byte, fileHeader header.Header) error {
+	chunks, err := newChunkDecryptor(key, fileHeader)
 	if err != nil {
 		return err
 	}
-	defer output.Close()
 
-	fmt.Printf("Decrypting %s...\n", config.InputPath)
+	record, err := salvageKeyRecord(input, fileHeader, chunks)
+	if err != nil {
+		return err
+	}
+	if _, err := input.Seek(bodyStart(), io.SeekStart); err != nil {
+		return fmt.Errorf("seek failed: %w", err)
+	}
+	if record == nil {
+		// Nothing is readable; salvage reports it once it is done
+		return nil
+	}
+
+	if _, err := chunks.ProcessChunk(record); err != nil {
+		return fmt.Errorf("decryption failed, the password is wrong: %w", err)
+	}
+	return nil
+}
+
+// salvageKeyRecord returns the chunk index record if its shards are
+// readable, else the first such chunk, or nil if there is none.
+func salvageKeyRecord(input *os.File, fileHeader header.Header, chunks *processor.ChunkProcessor) ([]byte, error) {
+	rs := chunks.ReedSolomon
+
+	info, err := input.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file info: %w", err)
+	}
+	trailerEnd := info.Size() - int64(header.EncodedSize())
+	if position, found, err := container.ReadLocator(input, trailerEnd); err == nil && found {
+		start := bodyStart() + position
+		if start >= bodyStart() && start < trailerEnd {
+			section := io.NewSectionReader(input, start, trailerEnd-start)
+			if record, err := container.ReadIndexRecord(section, section.Size()); err == nil {
+				if _, err := rs.Check(record); err == nil {
+					return record, nil
+				}
+			}
+		}
+	}
+
+	if _, err := input.Seek(bodyStart(), io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seek failed: %w", err)
+	}
+	reader := container.NewReader(input, rs.TotalShards(), int(fileHeader.StripeWidth.Value)).
+		WithChunkLimit(chunks.MaxEncodedSize(int(fileHeader.ChunkSize.Value))).
+		WithShardCheck(encoding.ShardIntact)
+	for {
+		chunk, err := reader.ReadChunk()
+		switch {
+		case err == io.EOF:
+			return nil, nil
+		case err != nil:
+			if _, err := reader.Resync(); err != nil {
+				return nil, nil
+			}
+			continue
+		}
+		if _, err := rs.Check(chunk); err == nil {
+			return chunk, nil
+		}
+	}
+}
 
-	if err = op.performDecryption(input, output, key, fileHeader); err != nil {
+// finishSalvage keeps a partially recovered output and records which parts
+// of it are missing. The encrypted file is never offered for deletion here.
+func (op *Operations) finishSalvage(config OperationConfig, output *os.File, fileHeader header.Header, damaged []worker.DamagedRange) error {
+	report := newSalvageReport(config, fileHeader.OriginalSize.Value, damaged)
+	if uint64(report.DamagedBytes) >= fileHeader.OriginalSize.Value {
 		output.Close()
 		os.Remove(config.OutputPath)
-		return err
+		return fmt.Errorf("no chunk could be decrypted, the password may be wrong")
 	}
 
-	if err = op.handleCleanup(config.InputPath, false); err != nil {
+	reportPath := config.OutputPath + salvageReportExtension
+	if err := writeSalvageReport(reportPath, report); err != nil {
 		return err
 	}
 
-	fmt.Printf("File %s decrypted successfully\n", config.OutputPath)
-	return nil
+	fmt.Printf("File %s salvaged: %d of %d bytes lost in %d chunk(s), see %s\n",
+		config.OutputPath, report.DamagedBytes, report.OriginalSize, len(damaged), reportPath)
+	return fmt.Errorf("%d chunk(s) could not be recovered", len(damaged))
+}
+
+func warnHeaderDamage(reader *header.HeaderReader) {
+	switch {
+	case reader.UsedBackup():
+		fmt.Println("Warning: the primary header is damaged beyond repair, using the backup copy. Run Repair to restore it.")
+	case reader.PrimaryStatus() == header.CopyCorrected:
+		fmt.Println("Warning: the primary header was damaged and has been corrected. Run Repair to restore it.")
+	}
 }
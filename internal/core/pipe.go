@@ -0,0 +1,81 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/hambosto/go-encryption/internal/progress"
+	"github.com/hambosto/go-encryption/pkg/stream"
+)
+
+// EncryptStream encrypts input of unknown length, such as a pipe, to
+// output. The primary header records an unknown size; the real size is
+// authenticated by the encrypted chunk index and repeated in the footer.
+func EncryptStream(ctx context.Context, input io.Reader, output io.Writer, config OperationConfig) error {
+	writer, err := stream.NewEncryptWriter(output, []byte(config.Password), &stream.Options{
+		ChunkSize:   config.ChunkSize,
+		StripeWidth: config.StripeWidth,
+	})
+	if err != nil {
+		return err
+	}
+
+	reporter := streamReporter(config)
+	reporter.Start(progress.OperationEncrypt, -1)
+
+	_, err = io.Copy(writer, &contextReader{ctx: ctx, reader: input, reporter: reporter})
+	if err == nil {
+		err = writer.Close()
+	}
+	reporter.Finish(err)
+
+	if err != nil {
+		return fmt.Errorf("encryption failed: %w", err)
+	}
+	return nil
+}
+
+// DecryptStream decrypts input, reading it strictly sequentially, to output.
+func DecryptStream(ctx context.Context, input io.Reader, output io.Writer, config OperationConfig) error {
+	reader, err := stream.NewDecryptReader(input, []byte(config.Password))
+	if err != nil {
+		return err
+	}
+
+	reporter := streamReporter(config)
+	reporter.Start(progress.OperationDecrypt, reader.Size())
+
+	_, err = io.Copy(output, &contextReader{ctx: ctx, reader: reader, reporter: reporter})
+	reporter.Finish(err)
+
+	if err != nil {
+		return fmt.Errorf("decryption failed: %w", err)
+	}
+	return nil
+}
+
+func streamReporter(config OperationConfig) progress.Reporter {
+	if config.Progress == nil {
+		return progress.Silent{}
+	}
+	return config.Progress
+}
+
+// contextReader stops a copy once ctx is cancelled and reports the bytes
+// passing through it.
+type contextReader struct {
+	ctx      context.Context
+	reader   io.Reader
+	reporter progress.Reporter
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := c.reader.Read(p)
+	c.reporter.Add(int64(n))
+	return n, err
+}
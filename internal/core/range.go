@@ -0,0 +1,220 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hambosto/go-encryption/internal/container"
+	"github.com/hambosto/go-encryption/internal/header"
+	"github.com/hambosto/go-encryption/internal/processor"
+)
+
+// DecryptRange writes length bytes of the original file, starting at
+// offset, to w. Only the chunks holding the range are read and decrypted;
+// they are located through the chunk index, or by scanning the record
+// headers of files written without one. A range running past the end of
+// the file is cut short.
+func DecryptRange(ctx context.Context, path string, password string, offset int64, length int64, w io.Writer) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open input file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to get file info: %w", err)
+	}
+
+	fileHeader, err := header.NewHeaderReader(header.NewBinaryHeaderIO()).Read(file)
+	if err != nil {
+		return fmt.Errorf("header reading failed: %w", err)
+	}
+	if fileHeader, err = resolveSize(file, fileHeader); err != nil {
+		return err
+	}
+
+	size := int64(fileHeader.OriginalSize.Value)
+	if offset < 0 || length < 0 || offset > size {
+		return fmt.Errorf("range %d+%d is outside the file of %d bytes", offset, length, size)
+	}
+	length = min(length, size-offset)
+	if length == 0 {
+		return nil
+	}
+
+	key, err := deriveKeyWithSalt(password, fileHeader.Salt.Value)
+	if err != nil {
+		return err
+	}
+
+	chunks, err := newChunkDecryptor(key, fileHeader)
+	if err != nil {
+		return err
+	}
+
+	offsets, err := loadRecordOffsets(file, info.Size(), fileHeader, chunks)
+	if err != nil {
+		return err
+	}
+
+	chunkSize := int64(fileHeader.ChunkSize.Value)
+	stripeWidth := int(fileHeader.StripeWidth.Value)
+	group := int64(max(stripeWidth, 1))
+	first := offset / chunkSize
+	last := (offset + length - 1) / chunkSize
+
+	record := first / group
+	if record >= int64(len(offsets)) {
+		return fmt.Errorf("chunk index does not cover chunk %d", first)
+	}
+	if _, err := file.Seek(bodyStart()+offsets[record], io.SeekStart); err != nil {
+		return fmt.Errorf("seek failed: %w", err)
+	}
+
+	// Chunks of a stripe group are stored together, so the ones before the
+	// range in its first group are read but not decrypted
+	reader := container.NewReader(file, chunks.ReedSolomon.TotalShards(), stripeWidth)
+	for index := record * group; index <= last; index++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		chunk, err := reader.ReadChunk()
+		if err != nil {
+			return fmt.Errorf("reading chunk %d: %w", index, err)
+		}
+		if index < first {
+			continue
+		}
+
+		plain, err := chunks.ProcessChunk(chunk)
+		if err != nil {
+			return fmt.Errorf("decrypting chunk %d: %w", index, err)
+		}
+
+		start := index * chunkSize
+		if want := min(chunkSize, size-start); int64(len(plain)) != want {
+			return fmt.Errorf("chunk %d decrypted to %d bytes, expected %d", index, len(plain), want)
+		}
+
+		from := max(offset-start, 0)
+		to := min(offset+length-start, int64(len(plain)))
+		if _, err := w.Write(plain[from:to]); err != nil {
+			return fmt.Errorf("write failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// newChunkDecryptor decrypts single chunks outside the worker pipeline.
+func newChunkDecryptor(key []byte, fileHeader header.Header) (*processor.ChunkProcessor, error) {
+	chunks, err := processor.NewChunkProcessor(key, false)
+	if err != nil {
+		return nil, fmt.Errorf("decryption processor creation failed: %w", err)
+	}
+	if err := chunks.AESCipher.SetNonce(fileHeader.AesNonce.Value); err != nil {
+		return nil, fmt.Errorf("AES nonce setting failed: %w", err)
+	}
+	if err := chunks.ChaCha20Cipher.SetNonce(fileHeader.ChaCha20Nonce.Value); err != nil {
+		return nil, fmt.Errorf("ChaCha20 nonce setting failed: %w", err)
+	}
+	return chunks, nil
+}
+
+// loadRecordOffsets reads the chunk index from the trailer, falling back to
+// scanning the body when the file has no index. An index that is present
+// but unusable is an error: it is either damaged, which Repair deals with,
+// or the password is wrong.
+func loadRecordOffsets(file *os.File, fileSize int64, fileHeader header.Header, chunks *processor.ChunkProcessor) ([]int64, error) {
+	stripeWidth := int(fileHeader.StripeWidth.Value)
+	trailerEnd := fileSize - int64(header.EncodedSize())
+
+	offsets, size, err := readIndex(file, trailerEnd, fileHeader, chunks)
+	if err != nil {
+		return nil, fmt.Errorf("chunk index is unusable, run Repair if the password is right: %w", err)
+	}
+	if offsets != nil {
+		if size != int64(fileHeader.OriginalSize.Value) {
+			return nil, fmt.Errorf("chunk index records %d bytes but the header %d", size, fileHeader.OriginalSize.Value)
+		}
+		return offsets, nil
+	}
+
+	if _, err := file.Seek(bodyStart(), io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seek failed: %w", err)
+	}
+	offsets, err = container.ScanRecords(file, chunks.ReedSolomon.TotalShards(), stripeWidth, 0)
+	if err != nil {
+		return nil, fmt.Errorf("scanning chunks failed: %w", err)
+	}
+	return offsets, nil
+}
+
+// readIndex returns the record offsets and the authenticated plaintext size
+// stored in the trailer, or nil offsets if the file has no index.
+func readIndex(file *os.File, trailerEnd int64, fileHeader header.Header, chunks *processor.ChunkProcessor) ([]int64, int64, error) {
+	position, ok, err := container.ReadLocator(file, trailerEnd)
+	if err != nil || !ok {
+		return nil, 0, err
+	}
+
+	start := bodyStart() + position
+	if start < bodyStart() || start >= trailerEnd {
+		return nil, 0, fmt.Errorf("index locator is corrupted")
+	}
+
+	section := io.NewSectionReader(file, start, trailerEnd-start)
+	record, err := container.ReadIndexRecord(section, section.Size())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	encoded, err := chunks.ProcessChunk(record)
+	if err != nil {
+		return nil, 0, fmt.Errorf("index decryption failed: %w", err)
+	}
+
+	offsets, size, err := container.DecodeIndex(encoded)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Every record but the last holds a full stripe group
+	chunkSize := int64(fileHeader.ChunkSize.Value)
+	total := (size + chunkSize - 1) / chunkSize
+	group := int64(max(fileHeader.StripeWidth.Value, 1))
+	if int64(len(offsets)) != (total+group-1)/group {
+		return nil, 0, fmt.Errorf("chunk index has %d entries for %d chunks", len(offsets), total)
+	}
+	return offsets, size, nil
+}
+
+// verifyStoredSize checks the size a streaming writer recorded in the
+// unauthenticated footer against the copy in the encrypted chunk index.
+func verifyStoredSize(file *os.File, fileHeader header.Header, key []byte) error {
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to get file info: %w", err)
+	}
+
+	chunks, err := newChunkDecryptor(key, fileHeader)
+	if err != nil {
+		return err
+	}
+
+	offsets, size, err := readIndex(file, info.Size()-int64(header.EncodedSize()), fileHeader, chunks)
+	if err != nil {
+		return fmt.Errorf("size could not be authenticated: %w", err)
+	}
+	if offsets == nil {
+		return fmt.Errorf("size could not be authenticated: the chunk index is missing")
+	}
+	if size != int64(fileHeader.OriginalSize.Value) {
+		return fmt.Errorf("file was truncated or altered: %d bytes recorded, %d in the footer", size, fileHeader.OriginalSize.Value)
+	}
+	return nil
+}
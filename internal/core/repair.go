@@ -0,0 +1,254 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/hambosto/go-encryption/internal/container"
+	"github.com/hambosto/go-encryption/internal/encoding"
+	"github.com/hambosto/go-encryption/internal/header"
+	"github.com/hambosto/go-encryption/internal/processor"
+)
+
+type RepairReport struct {
+	HeaderRepaired bool
+	IndexRepaired  bool
+	IndexDropped   bool
+	GroupHeaders   []int
+	Reframed       []uint32
+	Repaired       []uint32
+	Unrepairable   []uint32
+}
+
+func (r RepairReport) Damaged() bool {
+	return r.Healed() || len(r.Unrepairable) > 0
+}
+
+func (r RepairReport) Healed() bool {
+	return r.HeaderRepaired || r.IndexRepaired || r.IndexDropped || len(r.GroupHeaders) > 0 || len(r.Reframed) > 0 || len(r.Repaired) > 0
+}
+
+// handleRepair rebuilds damaged shards from parity and atomically replaces
+// the input with the healed copy. It works on ciphertext only, so no
+// password is needed.
+func (op *Operations) handleRepair(ctx context.Context, config OperationConfig) error {
+	input, inputInfo, err := op.fileManager.OpenInputFile(config.InputPath)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	reader := header.NewHeaderReader(header.NewBinaryHeaderIO())
+	fileHeader, err := reader.Read(input)
+	if err != nil {
+		return fmt.Errorf("header reading failed: %w", err)
+	}
+
+	// Both copies are rewritten with the size, should only the footer have it
+	if fileHeader, err = resolveSize(input, fileHeader); err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(config.InputPath), filepath.Base(config.InputPath)+".repair-*")
+	if err != nil {
+		return fmt.Errorf("failed to create repair file: %w", err)
+	}
+	defer os.Remove(temp.Name())
+	defer temp.Close()
+
+	fmt.Printf("Repairing %s...\n", config.InputPath)
+
+	report, err := op.performRepair(ctx, input, inputInfo.Size(), temp, fileHeader)
+	if err != nil {
+		return err
+	}
+
+	// Both header copies are rewritten, so either one being damaged is healed
+	reader.ReadBackup(input)
+	report.HeaderRepaired = reader.PrimaryStatus() != header.CopyIntact || reader.BackupStatus() != header.CopyIntact
+
+	printRepairReport(report)
+
+	if !report.Damaged() {
+		fmt.Printf("File %s is intact, nothing to repair\n", config.InputPath)
+		return nil
+	}
+
+	if report.Healed() {
+		if err := op.replaceFile(temp, config.InputPath, inputInfo.Mode()); err != nil {
+			return err
+		}
+		fmt.Printf("File %s repaired successfully\n", config.InputPath)
+	}
+
+	if len(report.Unrepairable) > 0 {
+		return fmt.Errorf("%d chunk(s) are beyond repair", len(report.Unrepairable))
+	}
+
+	return nil
+}
+
+func (op *Operations) performRepair(ctx context.Context, input *os.File, size int64, output io.Writer, fileHeader header.Header) (RepairReport, error) {
+	var report RepairReport
+
+	rs, err := encoding.NewReedSolomon(encoding.DefaultConfig())
+	if err != nil {
+		return report, fmt.Errorf("failed to create Reed-Solomon encoder: %w", err)
+	}
+
+	if err := header.NewHeaderWriter(header.NewBinaryHeaderIO()).Write(output, fileHeader); err != nil {
+		return report, fmt.Errorf("header writing failed: %w", err)
+	}
+
+	healed := container.NewWriter(output, rs.TotalShards(), int(fileHeader.StripeWidth.Value))
+
+	limit := processor.MaxEncodedSize(rs, int(fileHeader.ChunkSize.Value))
+	chunks := newBodyReader(input, fileHeader, rs.TotalShards()).WithChunkLimit(limit)
+	err = checkChunks(input, chunks, fileHeader, rs, func(index uint32, chunk checkedChunk) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// The healed copy gets the length prefix of the data it is written with
+		if chunk.reframed {
+			report.Reframed = append(report.Reframed, index)
+		}
+
+		repaired := chunk.data
+		switch {
+		case chunk.err != nil:
+			// Too many damaged shards, or parity that disagrees with the
+			// data; keep the original bytes so every other chunk stays
+			// decryptable
+			report.Unrepairable = append(report.Unrepairable, index)
+		case len(chunk.damaged) > 0:
+			var err error
+			if repaired, _, err = rs.Repair(chunk.data); err != nil {
+				return fmt.Errorf("repairing chunk %d: %w", index, err)
+			}
+			report.Repaired = append(report.Repaired, index)
+		}
+
+		if err := healed.WriteChunk(repaired); err != nil {
+			return fmt.Errorf("writing chunk %d: %w", index, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	if err := healed.Close(); err != nil {
+		return report, fmt.Errorf("writing repaired file: %w", err)
+	}
+
+	// The healed copy has both copies of every group header written afresh
+	report.GroupHeaders = chunks.DamagedHeaders()
+
+	if err := repairIndex(input, size, healed, rs, &report); err != nil {
+		return report, err
+	}
+
+	if err := header.NewHeaderWriter(header.NewBinaryHeaderIO()).Write(output, fileHeader); err != nil {
+		return report, fmt.Errorf("backup header writing failed: %w", err)
+	}
+
+	return report, nil
+}
+
+// repairIndex carries the chunk index over to the repaired file, with its
+// shards and locator rebuilt. An index beyond repair is left out; readers
+// then locate chunks by scanning the body instead.
+func repairIndex(input *os.File, size int64, healed *container.Writer, rs *encoding.ReedSolomon, report *RepairReport) error {
+	record, damaged, err := checkIndex(input, size, rs)
+	switch {
+	case err != nil:
+		report.IndexDropped = true
+		return nil
+	case record == nil:
+		return nil
+	}
+
+	if damaged {
+		if record, _, err = rs.Repair(record); err != nil {
+			return fmt.Errorf("repairing chunk index: %w", err)
+		}
+		report.IndexRepaired = true
+	}
+	if err := healed.WriteIndex(record); err != nil {
+		return fmt.Errorf("writing repaired file: %w", err)
+	}
+	return nil
+}
+
+// checkIndex checks the chunk index that follows the body, where input is
+// positioned, without the password. It returns the index record, or nil if
+// the file has none, and whether it is damaged: a shard that does not
+// match its checksum, or a locator that does not point at it. An index
+// beyond repair is an error.
+func checkIndex(input *os.File, size int64, rs *encoding.ReedSolomon) ([]byte, bool, error) {
+	position, err := input.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, false, fmt.Errorf("seek failed: %w", err)
+	}
+	trailerEnd := size - int64(header.EncodedSize())
+	if trailerEnd <= position {
+		return nil, false, nil
+	}
+
+	record, err := container.ReadIndexRecord(input, trailerEnd-position)
+	if err != nil {
+		return nil, true, err
+	}
+	damaged, err := rs.Check(record)
+	if err != nil {
+		return nil, true, fmt.Errorf("chunk index is beyond repair: %w", err)
+	}
+
+	offset, found, err := container.ReadLocator(input, trailerEnd)
+	located := err == nil && found && bodyStart()+offset == position
+	return record, len(damaged) > 0 || !located, nil
+}
+
+func (op *Operations) replaceFile(temp *os.File, path string, mode os.FileMode) error {
+	if err := temp.Chmod(mode); err != nil {
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+	if err := temp.Sync(); err != nil {
+		return fmt.Errorf("failed to sync repaired file: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("failed to close repaired file: %w", err)
+	}
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace original file: %w", err)
+	}
+	return nil
+}
+
+func printRepairReport(report RepairReport) {
+	if report.HeaderRepaired {
+		fmt.Println("Repaired header copies")
+	}
+	if report.IndexRepaired {
+		fmt.Println("Repaired chunk index")
+	}
+	if len(report.Reframed) > 0 {
+		fmt.Printf("Repaired length prefixes of chunks: %v\n", report.Reframed)
+	}
+	if len(report.GroupHeaders) > 0 {
+		fmt.Printf("Repaired stripe group headers: %v\n", report.GroupHeaders)
+	}
+	if report.IndexDropped {
+		fmt.Println("Removed damaged chunk index, it will not be used for range decryption")
+	}
+	if len(report.Repaired) > 0 {
+		fmt.Printf("Repaired chunks: %v\n", report.Repaired)
+	}
+	if len(report.Unrepairable) > 0 {
+		fmt.Printf("Chunks beyond repair: %v\n", report.Unrepairable)
+	}
+}
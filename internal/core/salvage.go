@@ -0,0 +1,47 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/hambosto/go-encryption/internal/worker"
+)
+
+const salvageReportExtension = ".salvage.json"
+
+// SalvageReport is written next to the output of a salvaged decryption and
+// lists the regions of the original file that could not be recovered.
+type SalvageReport struct {
+	Input         string                `json:"input"`
+	Output        string                `json:"output"`
+	Mode          string                `json:"mode"`
+	OriginalSize  uint64                `json:"original_size"`
+	DamagedBytes  int64                 `json:"damaged_bytes"`
+	DamagedRanges []worker.DamagedRange `json:"damaged_ranges"`
+}
+
+func newSalvageReport(config OperationConfig, originalSize uint64, ranges []worker.DamagedRange) SalvageReport {
+	report := SalvageReport{
+		Input:         config.InputPath,
+		Output:        config.OutputPath,
+		Mode:          config.Salvage.String(),
+		OriginalSize:  originalSize,
+		DamagedRanges: ranges,
+	}
+	for _, r := range ranges {
+		report.DamagedBytes += r.Length
+	}
+	return report
+}
+
+func writeSalvageReport(path string, report SalvageReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode salvage report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write salvage report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hambosto/go-encryption/internal/encoding"
+	"github.com/hambosto/go-encryption/internal/header"
+	"github.com/hambosto/go-encryption/internal/processor"
+)
+
+type ScrubStatus string
+
+const (
+	ScrubHealthy    ScrubStatus = "healthy"
+	ScrubRepairable ScrubStatus = "repairable"
+	ScrubDamaged    ScrubStatus = "damaged"
+)
+
+type ScrubResult struct {
+	Path             string      `json:"path"`
+	Status           ScrubStatus `json:"status"`
+	Chunks           uint32      `json:"chunks"`
+	HeaderDamaged    bool        `json:"header_damaged,omitempty"`
+	DamagedGroups    []int       `json:"damaged_group_headers,omitempty"`
+	BadLengthChunks  []uint32    `json:"bad_length_chunks,omitempty"`
+	IndexDamaged     bool        `json:"index_damaged,omitempty"`
+	RepairableChunks []uint32    `json:"repairable_chunks,omitempty"`
+	DamagedChunks    []uint32    `json:"damaged_chunks,omitempty"`
+	Error            string      `json:"error,omitempty"`
+}
+
+type ScrubReport struct {
+	Files []ScrubResult `json:"files"`
+}
+
+func (r ScrubReport) Healthy() bool {
+	for _, file := range r.Files {
+		if file.Status != ScrubHealthy {
+			return false
+		}
+	}
+	return true
+}
+
+func (r ScrubReport) WriteText(w io.Writer) error {
+	var b strings.Builder
+	var unhealthy int
+
+	for _, file := range r.Files {
+		fmt.Fprintf(&b, "%-10s %s (%d chunks)\n", file.Status, file.Path, file.Chunks)
+		if file.HeaderDamaged {
+			fmt.Fprintf(&b, "           header: a copy is damaged\n")
+		}
+		if len(file.DamagedGroups) > 0 {
+			fmt.Fprintf(&b, "           stripe group headers with a damaged copy: %v\n", file.DamagedGroups)
+		}
+		if file.IndexDamaged {
+			fmt.Fprintf(&b, "           chunk index: damaged\n")
+		}
+		if len(file.BadLengthChunks) > 0 {
+			fmt.Fprintf(&b, "           chunks with a corrupted length prefix: %v\n", file.BadLengthChunks)
+		}
+		if len(file.RepairableChunks) > 0 {
+			fmt.Fprintf(&b, "           repairable chunks: %v\n", file.RepairableChunks)
+		}
+		if len(file.DamagedChunks) > 0 {
+			fmt.Fprintf(&b, "           damaged chunks: %v\n", file.DamagedChunks)
+		}
+		if file.Error != "" {
+			fmt.Fprintf(&b, "           error: %s\n", file.Error)
+		}
+		if file.Status != ScrubHealthy {
+			unhealthy++
+		}
+	}
+	fmt.Fprintf(&b, "Scrubbed %d file(s), %d unhealthy\n", len(r.Files), unhealthy)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (r ScrubReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// Scrub checks the header and every chunk of the given files without the
+// password. Directories are walked recursively for files with the encrypted
+// extension; files named explicitly are scrubbed whatever their name.
+func Scrub(paths []string) (ScrubReport, error) {
+	var report ScrubReport
+
+	rs, err := encoding.NewReedSolomon(encoding.DefaultConfig())
+	if err != nil {
+		return report, fmt.Errorf("failed to create Reed-Solomon encoder: %w", err)
+	}
+
+	for _, path := range paths {
+		files, err := collectScrubTargets(path)
+		if err != nil {
+			return report, err
+		}
+		for _, file := range files {
+			report.Files = append(report.Files, scrubFile(file, rs))
+		}
+	}
+
+	return report, nil
+}
+
+func collectScrubTargets(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && strings.HasSuffix(p, encExtension) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %w", path, err)
+	}
+	return files, nil
+}
+
+func scrubFile(path string, rs *encoding.ReedSolomon) ScrubResult {
+	result := ScrubResult{Path: path, Status: ScrubHealthy}
+
+	file, err := os.Open(path)
+	if err != nil {
+		result.Status = ScrubDamaged
+		result.Error = err.Error()
+		return result
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		result.Status = ScrubDamaged
+		result.Error = err.Error()
+		return result
+	}
+
+	reader := header.NewHeaderReader(header.NewBinaryHeaderIO())
+	fileHeader, err := reader.Read(file)
+	if err != nil {
+		result.Status = ScrubDamaged
+		result.Error = fmt.Sprintf("header reading failed: %v", err)
+		return result
+	}
+
+	limit := processor.MaxEncodedSize(rs, int(fileHeader.ChunkSize.Value))
+	chunks := newBodyReader(file, fileHeader, rs.TotalShards()).WithChunkLimit(limit)
+	err = checkChunks(file, chunks, fileHeader, rs, func(index uint32, chunk checkedChunk) error {
+		result.Chunks++
+		if chunk.reframed {
+			result.BadLengthChunks = append(result.BadLengthChunks, index)
+		}
+		switch {
+		case chunk.err != nil:
+			result.DamagedChunks = append(result.DamagedChunks, index)
+		case len(chunk.damaged) > 0:
+			result.RepairableChunks = append(result.RepairableChunks, index)
+		}
+		return nil
+	})
+
+	if err == nil {
+		// Repair rebuilds a damaged index, or leaves out one beyond repair
+		_, damaged, indexErr := checkIndex(file, info.Size(), rs)
+		result.IndexDamaged = damaged || indexErr != nil
+
+		reader.ReadBackup(file)
+		result.HeaderDamaged = reader.PrimaryStatus() != header.CopyIntact || reader.BackupStatus() != header.CopyIntact
+	}
+	result.DamagedGroups = chunks.DamagedHeaders()
+
+	switch {
+	case err != nil:
+		// The rest of the body cannot be located once the framing is lost
+		result.Status = ScrubDamaged
+		result.Error = err.Error()
+	case len(result.DamagedChunks) > 0:
+		result.Status = ScrubDamaged
+	case len(result.RepairableChunks) > 0, len(result.BadLengthChunks) > 0, result.IndexDamaged, result.HeaderDamaged, len(result.DamagedGroups) > 0:
+		result.Status = ScrubRepairable
+	}
+
+	return result
+}
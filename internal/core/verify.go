@@ -0,0 +1,135 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+
+	"github.com/hambosto/go-encryption/internal/header"
+	"github.com/hambosto/go-encryption/internal/worker"
+)
+
+// handleVerify decrypts the whole file with the password and discards the
+// plaintext, proving the file can be restored without writing anything.
+func (op *Operations) handleVerify(ctx context.Context, config OperationConfig) error {
+	input, _, err := op.fileManager.OpenInputFile(config.InputPath)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	fileHeader, key, err := op.unlock(input, config)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Verifying %s...\n", config.InputPath)
+	digest, err := plaintextDigest(ctx, input, key, fileHeader, config)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("File %s verified: %d bytes, SHA-256 %x\n", config.InputPath, fileHeader.OriginalSize.Value, digest)
+	return nil
+}
+
+// plaintextDigest decrypts the body of input, positioned just past the
+// header, and returns the SHA-256 of the plaintext. Every chunk must
+// authenticate and the plaintext must have the length in the header.
+func plaintextDigest(ctx context.Context, input io.Reader, key []byte, fileHeader header.Header, config OperationConfig) ([]byte, error) {
+	config.Salvage = worker.SalvageOff
+	processor, err := newDecryptionStream(key, fileHeader, config)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := &digestWriter{hash: sha256.New()}
+	if err := processor.ProcessContext(ctx, input, digest, int64(fileHeader.OriginalSize.Value)); err != nil {
+		var pipelineErr *worker.PipelineError
+		if errors.As(err, &pipelineErr) {
+			return nil, fmt.Errorf("verification failed at chunk %d: %w", pipelineErr.Chunk, pipelineErr.Err)
+		}
+		return nil, fmt.Errorf("verification failed: %w", err)
+	}
+
+	if uint64(digest.size) != fileHeader.OriginalSize.Value {
+		return nil, fmt.Errorf("verification failed: decrypted %d bytes, the header records %d", digest.size, fileHeader.OriginalSize.Value)
+	}
+	return digest.hash.Sum(nil), nil
+}
+
+// roundTripCheck returns the check run before the original of an
+// encryption is deleted: the new file is decrypted again and must give back
+// exactly the plaintext that digest saw while encrypting. A nil digest
+// hashes the original again instead. It returns nil with SkipVerify.
+func (op *Operations) roundTripCheck(ctx context.Context, config OperationConfig, key []byte, digest hash.Hash) func() error {
+	if config.SkipVerify {
+		return nil
+	}
+
+	return func() error {
+		var expected []byte
+		if digest != nil {
+			expected = digest.Sum(nil)
+		} else {
+			var err error
+			if expected, err = fileDigest(config.InputPath); err != nil {
+				return err
+			}
+		}
+
+		output, err := os.Open(config.OutputPath)
+		if err != nil {
+			return fmt.Errorf("failed to reopen %s: %w", config.OutputPath, err)
+		}
+		defer output.Close()
+
+		fileHeader, err := header.NewHeaderReader(header.NewBinaryHeaderIO()).Read(output)
+		if err != nil {
+			return fmt.Errorf("header reading failed: %w", err)
+		}
+
+		fmt.Printf("Verifying %s before deleting the original...\n", config.OutputPath)
+		decrypted, err := plaintextDigest(ctx, output, key, fileHeader, config)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(decrypted, expected) {
+			return fmt.Errorf("%s does not decrypt to the original", config.OutputPath)
+		}
+		return nil
+	}
+}
+
+func fileDigest(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	digest := sha256.New()
+	if _, err := io.Copy(digest, file); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return digest.Sum(nil), nil
+}
+
+type digestWriter struct {
+	hash hash.Hash
+	size int64
+}
+
+func (d *digestWriter) Write(p []byte) (int, error) {
+	d.size += int64(len(p))
+	return d.hash.Write(p)
+}
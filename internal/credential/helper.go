@@ -0,0 +1,122 @@
+// Package credential asks an external command for passwords, in the manner
+// of git's credential helpers.
+//
+// The helper is run with one argument, the action, and reads attributes as
+// key=value lines from stdin, ended by an empty line:
+//
+//	get    operation, path and output are given; the helper prints
+//	       password=... or nothing if it does not know the password
+//	store  the same attributes plus password, for a newly chosen password
+//
+// Unknown attributes must be ignored by both sides.
+package credential
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const (
+	ActionGet   = "get"
+	ActionStore = "store"
+)
+
+// Request identifies the file a password is wanted for.
+type Request struct {
+	Operation string
+	Path      string
+	Output    string
+}
+
+// Helper runs a credential helper command through the shell, so the
+// command may carry its own arguments.
+type Helper struct {
+	command string
+}
+
+func NewHelper(command string) *Helper {
+	return &Helper{command: command}
+}
+
+// Get returns the password the helper holds for req, or an empty string if
+// it has none.
+func (h *Helper) Get(req Request) (string, error) {
+	attributes, err := h.run(ActionGet, req.attributes())
+	if err != nil {
+		return "", err
+	}
+	return attributes["password"], nil
+}
+
+// Store hands a newly chosen password for req to the helper.
+func (h *Helper) Store(req Request, password string) error {
+	_, err := h.run(ActionStore, append(req.attributes(), "password="+password))
+	return err
+}
+
+func (h *Helper) run(action string, attributes []string) (map[string]string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", h.command+" "+action)
+	} else {
+		cmd = exec.Command("sh", "-c", h.command+` "$@"`, h.command, action)
+	}
+
+	var input bytes.Buffer
+	for _, attribute := range attributes {
+		if strings.ContainsAny(attribute, "\n\x00") {
+			// Only the key is reported, the value may be the password
+			key, _, _ := strings.Cut(attribute, "=")
+			return nil, fmt.Errorf("credential helper: value of %s contains a newline", key)
+		}
+		input.WriteString(attribute + "\n")
+	}
+	input.WriteString("\n")
+
+	var output bytes.Buffer
+	cmd.Stdin = &input
+	cmd.Stdout = &output
+	// The helper may need to talk to the user, e.g. to unlock a vault
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("credential helper %s failed: %w", action, err)
+	}
+	return parseAttributes(&output), nil
+}
+
+func (r Request) attributes() []string {
+	attributes := []string{"operation=" + r.Operation, "path=" + absolute(r.Path)}
+	if r.Output != "" {
+		attributes = append(attributes, "output="+absolute(r.Output))
+	}
+	return attributes
+}
+
+func parseAttributes(output *bytes.Buffer) map[string]string {
+	attributes := make(map[string]string)
+	scanner := bufio.NewScanner(output)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			break
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			attributes[key] = value
+		}
+	}
+	return attributes
+}
+
+func absolute(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
@@ -0,0 +1,127 @@
+package credential
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/hambosto/go-encryption/internal/core"
+)
+
+// writeStub writes a helper script that saves its input to a log file named
+// after the action, then runs body. It returns the command and the
+// directory holding the logs.
+func writeStub(t *testing.T, body string) (string, string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the stub helper is a shell script")
+	}
+
+	dir := t.TempDir()
+	script := "#!/bin/sh\ncat > \"" + dir + "/$1.log\"\n" + body + "\n"
+	path := filepath.Join(dir, "helper")
+	if err := os.WriteFile(path, []byte(script), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	return path, dir
+}
+
+func readLog(t *testing.T, dir string, action string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, action+".log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// fallbackPrompt answers the password question only, and counts how often
+// it was asked.
+type fallbackPrompt struct {
+	core.PromptInterface
+	password string
+	asked    int
+}
+
+func (p *fallbackPrompt) GetPassword() (string, error) {
+	p.asked++
+	return p.password, nil
+}
+
+func TestHelperGet(t *testing.T) {
+	command, dir := writeStub(t, `printf 'user=someone\npassword=from helper\n\n'`)
+	request := Request{Operation: "decrypt", Path: "/data/file.enc", Output: "/data/file"}
+
+	password, err := NewHelper(command).Get(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if password != "from helper" {
+		t.Fatalf("got password %q, want %q", password, "from helper")
+	}
+
+	want := "operation=decrypt\npath=/data/file.enc\noutput=/data/file\n\n"
+	if got := readLog(t, dir, ActionGet); got != want {
+		t.Fatalf("helper got %q, want %q", got, want)
+	}
+}
+
+func TestPromptFallsBackOnEmptyReply(t *testing.T) {
+	command, _ := writeStub(t, `exit 0`)
+	fallback := &fallbackPrompt{password: "typed"}
+	prompt := NewPrompt(fallback, NewHelper(command), Request{Operation: "encrypt", Path: "/data/file"})
+
+	password, err := prompt.GetPassword()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if password != "typed" || fallback.asked != 1 {
+		t.Fatalf("got password %q after %d prompts, want %q after 1", password, fallback.asked, "typed")
+	}
+}
+
+func TestHelperStore(t *testing.T) {
+	command, dir := writeStub(t, `exit 0`)
+	fallback := &fallbackPrompt{password: "chosen"}
+	prompt := NewPrompt(fallback, NewHelper(command), Request{Operation: "encrypt", Path: "/data/file", Output: "/data/file.enc"})
+
+	if _, err := prompt.GetPassword(); err != nil {
+		t.Fatal(err)
+	}
+	if err := prompt.StoreChosen(); err != nil {
+		t.Fatal(err)
+	}
+
+	log := readLog(t, dir, ActionStore)
+	for _, want := range []string{"operation=encrypt\n", "path=/data/file\n", "password=chosen\n"} {
+		if !strings.Contains(log, want) {
+			t.Fatalf("store input %q lacks %q", log, want)
+		}
+	}
+}
+
+func TestHelperFailure(t *testing.T) {
+	command, _ := writeStub(t, `printf 'password=ignored\n'; exit 3`)
+	helper := NewHelper(command)
+
+	if _, err := helper.Get(Request{Operation: "decrypt", Path: "/data/file.enc"}); err == nil {
+		t.Fatal("get succeeded for a helper exiting with status 3")
+	}
+	if err := helper.Store(Request{Operation: "encrypt", Path: "/data/file"}, "pw"); err == nil {
+		t.Fatal("store succeeded for a helper exiting with status 3")
+	}
+}
+
+func TestHelperRejectsNewlineWithoutLeakingValue(t *testing.T) {
+	command, _ := writeStub(t, `exit 0`)
+
+	err := NewHelper(command).Store(Request{Operation: "encrypt", Path: "/data/file"}, "secret\nline")
+	if err == nil {
+		t.Fatal("store succeeded for a password with a newline")
+	}
+	if strings.Contains(err.Error(), "secret") {
+		t.Fatalf("error %q reveals the password", err)
+	}
+}
@@ -0,0 +1,43 @@
+package credential
+
+import (
+	"github.com/hambosto/go-encryption/internal/core"
+)
+
+// Prompt takes the password from a helper and only asks the wrapped prompt
+// when the helper has none. Every other question goes to the wrapped prompt.
+type Prompt struct {
+	core.PromptInterface
+	helper  *Helper
+	request Request
+	chosen  string
+}
+
+func NewPrompt(fallback core.PromptInterface, helper *Helper, request Request) *Prompt {
+	return &Prompt{PromptInterface: fallback, helper: helper, request: request}
+}
+
+func (p *Prompt) GetPassword() (string, error) {
+	password, err := p.helper.Get(p.request)
+	if err != nil {
+		return "", err
+	}
+	if password != "" {
+		return password, nil
+	}
+
+	if password, err = p.PromptInterface.GetPassword(); err != nil {
+		return "", err
+	}
+	p.chosen = password
+	return password, nil
+}
+
+// StoreChosen hands the password the user typed, if any, to the helper. It
+// is meant to be called once the operation has succeeded.
+func (p *Prompt) StoreChosen() error {
+	if p.chosen == "" {
+		return nil
+	}
+	return p.helper.Store(p.request, p.chosen)
+}
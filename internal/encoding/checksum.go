@@ -0,0 +1,30 @@
+package encoding
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// putChecksum writes the checksum of the first payloadLen bytes of stored
+// right after them.
+func putChecksum(stored []byte, payloadLen int) {
+	binary.BigEndian.PutUint32(stored[payloadLen:], crc32.Checksum(stored[:payloadLen], castagnoli))
+}
+
+func verifyChecksum(stored []byte) ([]byte, bool) {
+	if len(stored) <= checksumLength {
+		return nil, false
+	}
+	payload := stored[:len(stored)-checksumLength]
+	expected := binary.BigEndian.Uint32(stored[len(payload):])
+	return payload, crc32.Checksum(payload, castagnoli) == expected
+}
+
+// ShardIntact reports whether a stored shard, its payload followed by the
+// checksum, is undamaged.
+func ShardIntact(stored []byte) bool {
+	_, ok := verifyChecksum(stored)
+	return ok
+}
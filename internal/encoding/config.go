@@ -1,6 +1,15 @@
 package encoding
 
+const (
+	DefaultDataShards   = 4
+	DefaultParityShards = 10
+)
+
 type ReedSolomonConfig struct {
 	DataShards   int
 	ParityShards int
 }
+
+func DefaultConfig() ReedSolomonConfig {
+	return ReedSolomonConfig{DataShards: DefaultDataShards, ParityShards: DefaultParityShards}
+}
@@ -4,9 +4,36 @@ import (
 	"encoding/binary"
 )
 
-func addHeader(data []byte) []byte {
-	dataWithHeader := make([]byte, headerLength+len(data))
-	binary.BigEndian.PutUint32(dataWithHeader, uint32(len(data)))
-	copy(dataWithHeader[headerLength:], data)
-	return dataWithHeader
+// writeHeader spreads the length-prefixed data across the data shards in
+// order. The shards must be zeroed and large enough to hold it.
+func writeHeader(shards [][]byte, data []byte) {
+	var sizeHeader [headerLength]byte
+	binary.BigEndian.PutUint32(sizeHeader[:], uint32(len(data)))
+
+	scatter(shards, 0, sizeHeader[:])
+	scatter(shards, headerLength, data)
+}
+
+// scatter copies src into the concatenation of shards starting at offset.
+func scatter(shards [][]byte, offset int, src []byte) {
+	shardSize := len(shards[0])
+	for len(src) > 0 {
+		n := copy(shards[offset/shardSize][offset%shardSize:], src)
+		src = src[n:]
+		offset += n
+	}
+}
+
+// gather appends length bytes of the concatenation of shards, starting at
+// offset, to dst.
+func gather(dst []byte, shards [][]byte, offset, length int) []byte {
+	shardSize := len(shards[0])
+	for length > 0 {
+		chunk := shards[offset/shardSize][offset%shardSize:]
+		chunk = chunk[:min(len(chunk), length)]
+		dst = append(dst, chunk...)
+		offset += len(chunk)
+		length -= len(chunk)
+	}
+	return dst
 }
@@ -8,8 +8,9 @@ import (
 )
 
 const (
-	headerLength = 4
-	maxDataSize  = 1 << 30
+	headerLength   = 4
+	checksumLength = 4
+	maxDataSize    = 1 << 30
 )
 
 type ReedSolomon struct {
@@ -42,95 +43,208 @@ func (r *ReedSolomon) Encode(data []byte) ([]byte, error) {
 	return r.prepareAndEncode(data)
 }
 
+func (r *ReedSolomon) TotalShards() int {
+	return r.dataShards + r.parityShards
+}
+
+// EncodedSize returns the length of the output of Encode for an input of
+// dataLen bytes.
+func (r *ReedSolomon) EncodedSize(dataLen int) int {
+	shardSize := r.shardSize(headerLength + dataLen)
+	return (shardSize + checksumLength) * r.TotalShards()
+}
+
 func (r *ReedSolomon) Decode(data []byte) ([]byte, error) {
+	return r.DecodeTo(nil, data)
+}
+
+// DecodeTo appends the decoded data to dst, which lets callers reuse a
+// buffer across chunks.
+func (r *ReedSolomon) DecodeTo(dst, data []byte) ([]byte, error) {
 	if err := validateEncodedData(data, r.dataShards+r.parityShards); err != nil {
 		return nil, err
 	}
-	return r.reconstructAndDecode(data)
+	return r.reconstructAndDecode(dst, data)
 }
 
+// prepareAndEncode lays the shards out directly in the output buffer, so
+// encoding costs a single allocation.
 func (r *ReedSolomon) prepareAndEncode(data []byte) ([]byte, error) {
-	dataWithHeader := addHeader(data)
-	shards := r.splitIntoShards(dataWithHeader)
+	shardSize := r.shardSize(headerLength + len(data))
+	storedSize := shardSize + checksumLength
+	result := make([]byte, storedSize*r.TotalShards())
+
+	shards := make([][]byte, r.TotalShards())
+	for i := range shards {
+		shards[i] = result[i*storedSize : i*storedSize+shardSize]
+	}
+	writeHeader(shards, data)
 
 	if err := r.encoder.Encode(shards); err != nil {
 		return nil, fmt.Errorf("encoding failed: %w", err)
 	}
 
-	return r.joinShards(shards), nil
+	for i := range shards {
+		putChecksum(result[i*storedSize:(i+1)*storedSize], shardSize)
+	}
+
+	return result, nil
 }
 
-func (r *ReedSolomon) reconstructAndDecode(data []byte) ([]byte, error) {
-	shards := r.splitIntoDecodingShards(data)
+func (r *ReedSolomon) reconstructAndDecode(dst, data []byte) ([]byte, error) {
+	shards, damaged := r.splitIntoDecodingShards(data)
+	if err := r.checkRecoverable(damaged); err != nil {
+		return nil, err
+	}
 
 	if err := r.encoder.Reconstruct(shards); err != nil {
 		return nil, fmt.Errorf("recontruction failed: %w", err)
 	}
 
-	return r.extractOriginalData(shards)
+	return r.extractOriginalData(dst, shards)
 }
 
-func (r *ReedSolomon) splitIntoShards(data []byte) [][]byte {
-	totalShards := r.dataShards + r.parityShards
-	shardSize := (len(data) + r.dataShards - 1) / r.dataShards
+// Repair rebuilds the shards of an encoded chunk whose checksums do not
+// match and returns the healed chunk together with the indices of the
+// damaged shards. It needs no key, so it works on ciphertext alone. Like
+// Check, it fails for a chunk whose parity disagrees with its data, as the
+// rebuilt shards could not be trusted.
+func (r *ReedSolomon) Repair(data []byte) ([]byte, []int, error) {
+	if err := validateEncodedData(data, r.TotalShards()); err != nil {
+		return nil, nil, err
+	}
+
+	shards, damaged := r.splitIntoDecodingShards(data)
+	if err := r.checkRecoverable(damaged); err != nil {
+		return nil, damaged, err
+	}
 
-	if shardSize%r.dataShards != 0 {
-		shardSize = ((shardSize + r.dataShards - 1) / r.dataShards) * r.dataShards
+	if err := r.encoder.Reconstruct(shards); err != nil {
+		return nil, damaged, fmt.Errorf("recontruction failed: %w", err)
+	}
+	if err := r.verify(shards); err != nil {
+		return nil, damaged, err
 	}
 
-	shards := make([][]byte, totalShards)
-	for i := range shards {
-		shards[i] = make([]byte, shardSize)
+	if len(damaged) == 0 {
+		return data, nil, nil
+	}
+	return r.joinShards(shards), damaged, nil
+}
+
+// Check reports the damaged shards of an encoded chunk without modifying it.
+// A chunk whose shards pass their checksums but whose parity disagrees with
+// its data cannot be located and is reported as unrecoverable.
+func (r *ReedSolomon) Check(data []byte) ([]int, error) {
+	if err := validateEncodedData(data, r.TotalShards()); err != nil {
+		return nil, err
 	}
 
-	for i := range data {
-		shardNum := i / shardSize
-		indexInShard := i % shardSize
-		shards[shardNum][indexInShard] = data[i]
+	shards, damaged := r.splitIntoDecodingShards(data)
+	if err := r.checkRecoverable(damaged); err != nil {
+		return damaged, err
+	}
+
+	if err := r.encoder.Reconstruct(shards); err != nil {
+		return damaged, fmt.Errorf("recontruction failed: %w", err)
 	}
 
-	return shards
+	return damaged, r.verify(shards)
 }
 
-func (r *ReedSolomon) splitIntoDecodingShards(data []byte) [][]byte {
-	totalShards := r.dataShards + r.parityShards
-	shardSize := len(data) / totalShards
+func (r *ReedSolomon) verify(shards [][]byte) error {
+	consistent, err := r.encoder.Verify(shards)
+	if err != nil {
+		return fmt.Errorf("verification failed: %w", err)
+	}
+	if !consistent {
+		return fmt.Errorf("parity shards do not match data shards")
+	}
+	return nil
+}
+
+func (r *ReedSolomon) checkRecoverable(damaged []int) error {
+	if len(damaged) > r.parityShards {
+		return fmt.Errorf("too many damaged shards: %d of %d, at most %d can be recovered", len(damaged), r.TotalShards(), r.parityShards)
+	}
+	return nil
+}
+
+func (r *ReedSolomon) shardSize(length int) int {
+	shardSize := (length + r.dataShards - 1) / r.dataShards
+
+	if shardSize%r.dataShards != 0 {
+		shardSize = ((shardSize + r.dataShards - 1) / r.dataShards) * r.dataShards
+	}
+
+	return shardSize
+}
+
+// splitIntoDecodingShards strips the per-shard checksums and leaves a nil
+// entry for every shard whose checksum does not match, so that Reconstruct
+// treats it as an erasure.
+func (r *ReedSolomon) splitIntoDecodingShards(data []byte) ([][]byte, []int) {
+	totalShards := r.TotalShards()
+	storedSize := len(data) / totalShards
 	shards := make([][]byte, totalShards)
+	var damaged []int
 
 	for i := range shards {
-		shards[i] = data[i*shardSize : (i+1)*shardSize]
+		payload, ok := verifyChecksum(data[i*storedSize : (i+1)*storedSize])
+		if !ok {
+			damaged = append(damaged, i)
+			continue
+		}
+		shards[i] = payload
 	}
 
-	return shards
+	return shards, damaged
 }
 
 func (r *ReedSolomon) joinShards(shards [][]byte) []byte {
-	shardSize := len(shards[0])
-	result := make([]byte, shardSize*(r.dataShards+r.parityShards))
+	storedSize := len(shards[0]) + checksumLength
+	result := make([]byte, storedSize*r.TotalShards())
 
 	for i, shard := range shards {
-		copy(result[i*shardSize:], shard)
+		copy(result[i*storedSize:], shard)
+		putChecksum(result[i*storedSize:(i+1)*storedSize], len(shard))
 	}
 
 	return result
 }
 
-func (r *ReedSolomon) extractOriginalData(shards [][]byte) ([]byte, error) {
-	shardsSize := len(shards[0])
-	result := make([]byte, shardsSize*r.dataShards)
+func (r *ReedSolomon) extractOriginalData(dst []byte, shards [][]byte) ([]byte, error) {
+	capacity := len(shards[0]) * r.dataShards
+	if capacity < headerLength {
+		return nil, fmt.Errorf("corrupted data: too short")
+	}
+
+	var sizeHeader [headerLength]byte
+	gather(sizeHeader[:0], shards[:r.dataShards], 0, headerLength)
 
-	for i := range r.dataShards {
-		copy(result[i*shardsSize:], shards[i])
+	originalSize := int(binary.BigEndian.Uint32(sizeHeader[:]))
+	if originalSize > capacity-headerLength {
+		return nil, fmt.Errorf("corrupted data: invalid size header")
 	}
 
-	if len(result) < headerLength {
-		return nil, fmt.Errorf("corrupted data: too short")
+	return gather(dst, shards[:r.dataShards], headerLength, originalSize), nil
+}
+
+// DecodeLegacy decodes a chunk written before shards carried checksums.
+// Without them damage cannot be located, so the parity is only verified.
+func (r *ReedSolomon) DecodeLegacy(data []byte) ([]byte, error) {
+	if err := validateEncodedData(data, r.TotalShards()); err != nil {
+		return nil, err
 	}
 
-	originalSize := binary.BigEndian.Uint32(result[:headerLength])
-	if originalSize > uint32(len(result)-headerLength) {
-		return nil, fmt.Errorf("corrupted data: invalid size header")
+	shardSize := len(data) / r.TotalShards()
+	shards := make([][]byte, r.TotalShards())
+	for i := range shards {
+		shards[i] = data[i*shardSize : (i+1)*shardSize]
 	}
 
-	return result[headerLength : headerLength+originalSize], nil
+	if err := r.verify(shards); err != nil {
+		return nil, err
+	}
+	return r.extractOriginalData(nil, shards)
 }
@@ -0,0 +1,56 @@
+package encoding
+
+import (
+	"bytes"
+	"testing"
+)
+
+func encodeTestChunk(t *testing.T) (*ReedSolomon, []byte, []byte) {
+	t.Helper()
+	rs, err := NewReedSolomon(DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := bytes.Repeat([]byte("reed-solomon "), 100)
+	encoded, err := rs.Encode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rs, data, encoded
+}
+
+func TestRepairRebuildsDamagedShards(t *testing.T) {
+	rs, data, encoded := encodeTestChunk(t)
+	stored := len(encoded) / rs.TotalShards()
+	for _, shard := range []int{0, 5, 13} {
+		encoded[shard*stored] ^= 0xff
+	}
+
+	repaired, damaged, err := rs.Repair(encoded)
+	if err != nil {
+		t.Fatalf("repair failed: %v", err)
+	}
+	if len(damaged) != 3 {
+		t.Fatalf("damaged shards %v, want 3 of them", damaged)
+	}
+	decoded, err := rs.Decode(repaired)
+	if err != nil || !bytes.Equal(decoded, data) {
+		t.Fatalf("repaired chunk does not decode to the original data: %v", err)
+	}
+}
+
+// A shard changed along with its checksum cannot be located, so the rebuilt
+// chunk would not match its parity and must not be passed off as repaired.
+func TestRepairRejectsInconsistentParity(t *testing.T) {
+	rs, _, encoded := encodeTestChunk(t)
+	stored := len(encoded) / rs.TotalShards()
+
+	// Rewrite data shard 1 with a valid checksum and damage shard 2 outright
+	encoded[stored] ^= 0xff
+	putChecksum(encoded[stored:2*stored], stored-checksumLength)
+	encoded[2*stored] ^= 0xff
+
+	if _, _, err := rs.Repair(encoded); err == nil {
+		t.Fatal("repair succeeded on a chunk whose parity disagrees with its data")
+	}
+}
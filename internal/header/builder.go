@@ -1,10 +1,18 @@
 package header
 
+import (
+	"fmt"
+)
+
 type Header struct {
+	Magic         Magic
+	Version       Version
 	Salt          Salt
 	OriginalSize  OriginalSize
 	AesNonce      AesNonce
 	ChaCha20Nonce ChaCha20Nonce
+	StripeWidth   StripeWidth
+	ChunkSize     ChunkSize
 }
 
 type HeaderBuilder struct {
@@ -13,7 +21,33 @@ type HeaderBuilder struct {
 }
 
 func NewHeaderBuilder() *HeaderBuilder {
-	return &HeaderBuilder{}
+	return &HeaderBuilder{
+		header: Header{
+			Magic:   Magic{Value: MagicBytes},
+			Version: Version{Value: CurrentVersion},
+		},
+	}
+}
+
+func (b *HeaderBuilder) WithMagic(magic []byte) *HeaderBuilder {
+	if b.err != nil {
+		return b
+	}
+	b.header.Magic = Magic{Value: magic}
+	b.err = b.header.Magic.Validate(magic)
+	return b
+}
+
+func (b *HeaderBuilder) WithVersion(version uint16) *HeaderBuilder {
+	if b.err != nil {
+		return b
+	}
+	if version != CurrentVersion {
+		b.err = fmt.Errorf("unsupported file format version %d, want %d", version, CurrentVersion)
+		return b
+	}
+	b.header.Version = Version{Value: version}
+	return b
 }
 
 func (b *HeaderBuilder) WithSalt(salt []byte) *HeaderBuilder {
@@ -51,6 +85,30 @@ func (b *HeaderBuilder) WithChaCha20Nonce(nonce []byte) *HeaderBuilder {
 	return b
 }
 
+func (b *HeaderBuilder) WithStripeWidth(width uint16) *HeaderBuilder {
+	if b.err != nil {
+		return b
+	}
+	if width > MaxStripeWidth {
+		b.err = fmt.Errorf("invalid stripe width: got %d, maximum is %d", width, MaxStripeWidth)
+		return b
+	}
+	b.header.StripeWidth = StripeWidth{Value: width}
+	return b
+}
+
+func (b *HeaderBuilder) WithChunkSize(size uint32) *HeaderBuilder {
+	if b.err != nil {
+		return b
+	}
+	if size < MinChunkSize || size > MaxChunkSize {
+		b.err = fmt.Errorf("invalid chunk size: got %d, must be between %d and %d", size, MinChunkSize, MaxChunkSize)
+		return b
+	}
+	b.header.ChunkSize = ChunkSize{Value: size}
+	return b
+}
+
 func (b *HeaderBuilder) Build() (Header, error) {
 	if b.err != nil {
 		return Header{}, b.err
@@ -1,9 +1,34 @@
 package header
 
 import (
+	"bytes"
 	"fmt"
 )
 
+type Magic struct {
+	Value []byte
+}
+
+func (m Magic) Size() int { return MagicSize }
+func (m Magic) Validate(data []byte) error {
+	if !bytes.Equal(data, MagicBytes) {
+		return fmt.Errorf("not an encrypted file: invalid magic bytes")
+	}
+	return nil
+}
+
+type Version struct {
+	Value uint16
+}
+
+func (v Version) Size() int { return VersionSize }
+func (v Version) Validate(data []byte) error {
+	if len(data) != VersionSize {
+		return fmt.Errorf("invalid version bytes: got %d, want %d", len(data), VersionSize)
+	}
+	return nil
+}
+
 type Salt struct {
 	Value []byte
 }
@@ -51,3 +76,30 @@ func (n ChaCha20Nonce) Validate(data []byte) error {
 	}
 	return nil
 }
+
+// StripeWidth is the number of consecutive chunks whose Reed-Solomon shards
+// are interleaved on disk. Zero and one both select the contiguous layout.
+type StripeWidth struct {
+	Value uint16
+}
+
+func (s StripeWidth) Size() int { return StripeWidthSize }
+func (s StripeWidth) Validate(data []byte) error {
+	if len(data) != StripeWidthSize {
+		return fmt.Errorf("invalid stripe width bytes: got %d, want %d", len(data), StripeWidthSize)
+	}
+	return nil
+}
+
+// ChunkSize is the length of every plaintext chunk except the last one.
+type ChunkSize struct {
+	Value uint32
+}
+
+func (c ChunkSize) Size() int { return ChunkSizeBytes }
+func (c ChunkSize) Validate(data []byte) error {
+	if len(data) != ChunkSizeBytes {
+		return fmt.Errorf("invalid chunk size bytes: got %d, want %d", len(data), ChunkSizeBytes)
+	}
+	return nil
+}
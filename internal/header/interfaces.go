@@ -2,6 +2,7 @@ package header
 
 import (
 	"io"
+	"math"
 )
 
 type HeaderComponent interface {
@@ -20,8 +21,26 @@ type HeaderIO interface {
 }
 
 const (
+	MagicSize         = 4
+	VersionSize       = 2
 	SaltSize          = 32
 	OriginalSizeBytes = 8
 	AesNonceSize      = 12
 	ChaCha20NonceSize = 24
+	StripeWidthSize   = 2
+	ChunkSizeBytes    = 4
 )
+
+const (
+	CurrentVersion = 2
+	MaxStripeWidth = 64
+	MinChunkSize   = 64 * 1024
+	MaxChunkSize   = 64 * 1024 * 1024
+
+	// UnknownSize is the original size recorded in the primary header by a
+	// writer that does not know it upfront. The footer copy, written once
+	// the size is known, records the real value.
+	UnknownSize = math.MaxUint64
+)
+
+var MagicBytes = []byte("GENC")
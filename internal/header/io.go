@@ -14,6 +14,12 @@ func NewBinaryHeaderIO() HeaderIO {
 
 func (bio *BinaryHeaderIO) WriteComponent(w io.Writer, component HeaderComponent) error {
 	switch c := component.(type) {
+	case Magic:
+		return bio.write(w, c.Value)
+	case Version:
+		buf := make([]byte, VersionSize)
+		binary.BigEndian.PutUint16(buf, c.Value)
+		return bio.write(w, buf)
 	case Salt:
 		return bio.write(w, c.Value)
 	case OriginalSize:
@@ -24,6 +30,14 @@ func (bio *BinaryHeaderIO) WriteComponent(w io.Writer, component HeaderComponent
 		return bio.write(w, c.Value)
 	case ChaCha20Nonce:
 		return bio.write(w, c.Value)
+	case StripeWidth:
+		buf := make([]byte, StripeWidthSize)
+		binary.BigEndian.PutUint16(buf, c.Value)
+		return bio.write(w, buf)
+	case ChunkSize:
+		buf := make([]byte, ChunkSizeBytes)
+		binary.BigEndian.PutUint32(buf, c.Value)
+		return bio.write(w, buf)
 	default:
 		return fmt.Errorf("unsupported component type")
 	}
@@ -0,0 +1,55 @@
+package header
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Files written before the header gained its magic, version and Reed-Solomon
+// protection start with the bare fields
+//
+//	salt | original size | aes nonce | chacha20 nonce
+//
+// followed by length-prefixed chunks of LegacyChunkSize bytes of plaintext,
+// whose shards carry no checksums, up to the end of the file.
+const (
+	LegacyVersion   = 1
+	LegacyChunkSize = 1024 * 1024
+)
+
+// LegacySize is the length of a version 1 header.
+func LegacySize() int {
+	return SaltSize + OriginalSizeBytes + AesNonceSize + ChaCha20NonceSize
+}
+
+// ReadLegacy reads a version 1 header and returns it as a current header
+// with the same salt, size and nonces, ready to be written in its place.
+func (r *HeaderReader) ReadLegacy(reader io.Reader) (Header, error) {
+	saltData, err := r.io.ReadComponent(reader, SaltSize)
+	if err != nil {
+		return Header{}, err
+	}
+
+	sizeData, err := r.io.ReadComponent(reader, OriginalSizeBytes)
+	if err != nil {
+		return Header{}, err
+	}
+
+	aesNonce, err := r.io.ReadComponent(reader, AesNonceSize)
+	if err != nil {
+		return Header{}, err
+	}
+
+	chaCha20Nonce, err := r.io.ReadComponent(reader, ChaCha20NonceSize)
+	if err != nil {
+		return Header{}, err
+	}
+
+	return NewHeaderBuilder().
+		WithSalt(saltData).
+		WithOriginalSize(binary.BigEndian.Uint64(sizeData)).
+		WithAesNonce(aesNonce).
+		WithChaCha20Nonce(chaCha20Nonce).
+		WithChunkSize(LegacyChunkSize).
+		Build()
+}
@@ -0,0 +1,41 @@
+package header
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/hambosto/go-encryption/internal/encoding"
+)
+
+// The serialized header is protected by its own Reed-Solomon code and is
+// written twice: once at the start of the file and once as a footer at the
+// very end, so a damaged primary copy can be recovered from the backup.
+var (
+	protectionConfig = encoding.ReedSolomonConfig{DataShards: 4, ParityShards: 4}
+	protectionOnce   sync.Once
+	protection       *encoding.ReedSolomon
+	protectionErr    error
+)
+
+func protector() (*encoding.ReedSolomon, error) {
+	protectionOnce.Do(func() {
+		protection, protectionErr = encoding.NewReedSolomon(protectionConfig)
+	})
+	if protectionErr != nil {
+		return nil, fmt.Errorf("failed to create header encoder: %w", protectionErr)
+	}
+	return protection, nil
+}
+
+// EncodedSize is the length on disk of each copy of the header.
+func EncodedSize() int {
+	rs, err := protector()
+	if err != nil {
+		return 0
+	}
+	return rs.EncodedSize(serializedSize())
+}
+
+func serializedSize() int {
+	return MagicSize + VersionSize + SaltSize + OriginalSizeBytes + AesNonceSize + ChaCha20NonceSize + StripeWidthSize + ChunkSizeBytes
+}
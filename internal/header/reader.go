@@ -1,21 +1,159 @@
 package header
 
 import (
+	"bytes"
 	"encoding/binary"
+	"fmt"
 	"io"
 )
 
+type CopyStatus int
+
+const (
+	// CopyIntact means the copy was read without any damage.
+	CopyIntact CopyStatus = iota
+	// CopyCorrected means damaged shards of the copy were rebuilt from parity.
+	CopyCorrected
+	// CopyUnreadable means the copy is beyond repair.
+	CopyUnreadable
+)
+
+func (s CopyStatus) String() string {
+	switch s {
+	case CopyIntact:
+		return "intact"
+	case CopyCorrected:
+		return "corrected"
+	default:
+		return "unreadable"
+	}
+}
+
 type HeaderReader struct {
-	io HeaderIO
+	io            HeaderIO
+	primaryStatus CopyStatus
+	backupStatus  CopyStatus
+	usedBackup    bool
 }
 
 func NewHeaderReader(io HeaderIO) *HeaderReader {
 	return &HeaderReader{io: io}
 }
 
+// Read reads the primary copy of the header at the current position. If it
+// is beyond repair and the reader can seek, the backup copy at the end of
+// the file is used instead and the reader is left just past the primary
+// copy, where the body starts.
 func (r *HeaderReader) Read(reader io.Reader) (Header, error) {
+	r.usedBackup = false
+
+	header, status, err := r.readCopy(reader)
+	r.primaryStatus = status
+	if err == nil {
+		return header, nil
+	}
+
+	seeker, ok := reader.(io.ReadSeeker)
+	if !ok {
+		return Header{}, err
+	}
+
+	start, seekErr := seeker.Seek(0, io.SeekCurrent)
+	if seekErr != nil {
+		return Header{}, err
+	}
+
+	header, backupErr := r.ReadBackup(seeker)
+	if backupErr != nil {
+		return Header{}, fmt.Errorf("%w; backup copy: %v", err, backupErr)
+	}
+
+	if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+		return Header{}, fmt.Errorf("seeking to body: %w", err)
+	}
+
+	r.usedBackup = true
+	return header, nil
+}
+
+// ReadBackup reads the backup copy of the header from the end of the file.
+// It does not restore the position of the reader.
+func (r *HeaderReader) ReadBackup(reader io.ReadSeeker) (Header, error) {
+	r.backupStatus = CopyUnreadable
+	if _, err := reader.Seek(-int64(EncodedSize()), io.SeekEnd); err != nil {
+		return Header{}, fmt.Errorf("seeking to backup header: %w", err)
+	}
+
+	header, status, err := r.readCopy(reader)
+	r.backupStatus = status
+	return header, err
+}
+
+// PrimaryStatus reports the condition of the primary copy seen by the last
+// call to Read.
+func (r *HeaderReader) PrimaryStatus() CopyStatus {
+	return r.primaryStatus
+}
+
+// BackupStatus reports the condition of the backup copy seen by the last
+// call to ReadBackup.
+func (r *HeaderReader) BackupStatus() CopyStatus {
+	return r.backupStatus
+}
+
+// UsedBackup reports whether the last call to Read fell back to the backup
+// copy.
+func (r *HeaderReader) UsedBackup() bool {
+	return r.usedBackup
+}
+
+func (r *HeaderReader) readCopy(reader io.Reader) (Header, CopyStatus, error) {
+	rs, err := protector()
+	if err != nil {
+		return Header{}, CopyUnreadable, err
+	}
+
+	encoded, err := r.io.ReadComponent(reader, EncodedSize())
+	if err != nil {
+		return Header{}, CopyUnreadable, err
+	}
+
+	repaired, damaged, err := rs.Repair(encoded)
+	if err != nil {
+		return Header{}, CopyUnreadable, fmt.Errorf("header is damaged beyond repair: %w", err)
+	}
+
+	status := CopyIntact
+	if len(damaged) > 0 {
+		status = CopyCorrected
+	}
+
+	data, err := rs.Decode(repaired)
+	if err != nil {
+		return Header{}, CopyUnreadable, fmt.Errorf("header decoding failed: %w", err)
+	}
+
+	header, err := r.parse(bytes.NewReader(data))
+	if err != nil {
+		return Header{}, CopyUnreadable, err
+	}
+
+	return header, status, nil
+}
+
+func (r *HeaderReader) parse(reader io.Reader) (Header, error) {
 	builder := NewHeaderBuilder()
 
+	magic, err := r.io.ReadComponent(reader, MagicSize)
+	if err != nil {
+		return Header{}, err
+	}
+
+	versionData, err := r.io.ReadComponent(reader, VersionSize)
+	if err != nil {
+		return Header{}, err
+	}
+
 	saltData, err := r.io.ReadComponent(reader, SaltSize)
 	if err != nil {
 		return Header{}, err
@@ -36,10 +174,24 @@ func (r *HeaderReader) Read(reader io.Reader) (Header, error) {
 		return Header{}, err
 	}
 
+	stripeWidth, err := r.io.ReadComponent(reader, StripeWidthSize)
+	if err != nil {
+		return Header{}, err
+	}
+
+	chunkSize, err := r.io.ReadComponent(reader, ChunkSizeBytes)
+	if err != nil {
+		return Header{}, err
+	}
+
 	return builder.
+		WithMagic(magic).
+		WithVersion(binary.BigEndian.Uint16(versionData)).
 		WithSalt(saltData).
 		WithOriginalSize(binary.BigEndian.Uint64(sizeData)).
 		WithAesNonce(aesNonce).
 		WithChaCha20Nonce(chaCha20Nonce).
+		WithStripeWidth(binary.BigEndian.Uint16(stripeWidth)).
+		WithChunkSize(binary.BigEndian.Uint32(chunkSize)).
 		Build()
 }
@@ -1,6 +1,8 @@
 package header
 
 import (
+	"bytes"
+	"fmt"
 	"io"
 )
 
@@ -12,12 +14,41 @@ func NewHeaderWriter(io HeaderIO) *HeaderWriter {
 	return &HeaderWriter{io: io}
 }
 
+// Write writes one Reed-Solomon protected copy of the header. Encrypted
+// files carry two copies, the second one after the body.
 func (w *HeaderWriter) Write(writer io.Writer, header Header) error {
+	rs, err := protector()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := w.serialize(&buf, header); err != nil {
+		return err
+	}
+
+	encoded, err := rs.Encode(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("header encoding failed: %w", err)
+	}
+
+	if _, err := writer.Write(encoded); err != nil {
+		return fmt.Errorf("writing header: %w", err)
+	}
+
+	return nil
+}
+
+func (w *HeaderWriter) serialize(writer io.Writer, header Header) error {
 	components := []HeaderComponent{
+		header.Magic,
+		header.Version,
 		header.Salt,
 		header.OriginalSize,
 		header.AesNonce,
 		header.ChaCha20Nonce,
+		header.StripeWidth,
+		header.ChunkSize,
 	}
 
 	for _, component := range components {
@@ -7,6 +7,11 @@ import (
 	"github.com/hambosto/go-encryption/internal/encoding"
 )
 
+const (
+	sizeHeaderLength = 4
+	tagOverhead      = 16
+)
+
 type ChunkProcessor struct {
 	AESCipher      *cipher.AESCipher
 	ChaCha20Cipher *cipher.ChaCha20Cipher
@@ -29,7 +34,7 @@ func NewChunkProcessor(key []byte, isEncryption bool) (*ChunkProcessor, error) {
 		return nil, fmt.Errorf("failed to create ChaCha20 cipher: %w", err)
 	}
 
-	reedSolomon, err := encoding.NewReedSolomon(encoding.ReedSolomonConfig{DataShards: 4, ParityShards: 10})
+	reedSolomon, err := encoding.NewReedSolomon(encoding.DefaultConfig())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Reed-Solomon encoder: %w", err)
 	}
@@ -48,3 +53,17 @@ func (c *ChunkProcessor) ProcessChunk(chunk []byte) ([]byte, error) {
 	}
 	return c.decrypt(chunk)
 }
+
+// MaxEncodedSize bounds the length of an encrypted chunk for a plaintext
+// chunk of chunkLen bytes, allowing for incompressible input.
+func (c *ChunkProcessor) MaxEncodedSize(chunkLen int) int {
+	return MaxEncodedSize(c.ReedSolomon, chunkLen)
+}
+
+// MaxEncodedSize is the bound of ChunkProcessor.MaxEncodedSize for callers
+// without a key, such as repair.
+func MaxEncodedSize(rs *encoding.ReedSolomon, chunkLen int) int {
+	compressed := chunkLen + chunkLen/16000*5 + 64
+	sealed := (sizeHeaderLength+compressed+15)&^15 + 2*tagOverhead
+	return rs.EncodedSize(sealed)
+}
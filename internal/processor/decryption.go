@@ -1,31 +1,35 @@
 package processor
 
 import (
+	"bytes"
 	"fmt"
-
-	"github.com/hambosto/go-encryption/internal/compression"
 )
 
 func (c *ChunkProcessor) decrypt(chunk []byte) ([]byte, error) {
-	decodedData, err := c.ReedSolomon.Decode(chunk)
+	s := getScratch()
+	defer putScratch(s)
+
+	decodedData, err := c.ReedSolomon.DecodeTo(s.outer[:0], chunk)
 	if err != nil {
 		return nil, fmt.Errorf("reed-solomon decoding failed: %w", err)
 	}
+	s.outer = decodedData
 
-	chaCha20Decrypted, err := c.ChaCha20Cipher.Decrypt(decodedData)
+	chaCha20Decrypted, err := c.ChaCha20Cipher.Open(s.inner[:0], decodedData)
 	if err != nil {
 		return nil, fmt.Errorf("ChaCha20 decryption failed: %w", err)
 	}
+	s.inner = chaCha20Decrypted
 
-	aesDecrypted, err := c.AESCipher.Decrypt(chaCha20Decrypted)
+	// GCM may decrypt in place, reusing the ChaCha20 output buffer
+	aesDecrypted, err := c.AESCipher.Open(chaCha20Decrypted[:0], chaCha20Decrypted)
 	if err != nil {
 		return nil, fmt.Errorf("AES decryption failed: %w", err)
 	}
 
-	decompressedData, err := compression.DecompressData(aesDecrypted)
-	if err != nil {
+	if err := s.decompressor.DecompressTo(&s.payload, aesDecrypted); err != nil {
 		return nil, fmt.Errorf("zlib decompression failed: %w", err)
 	}
 
-	return decompressedData, nil
+	return bytes.Clone(s.payload.Bytes()), nil
 }
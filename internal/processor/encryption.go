@@ -7,30 +7,43 @@ import (
 	"github.com/hambosto/go-encryption/internal/compression"
 )
 
+// zeros supplies the size header placeholder and the alignment padding
+var zeros [16]byte
+
 func (c *ChunkProcessor) encrypt(chunk []byte) ([]byte, error) {
-	compressedData, err := compression.CompressData(chunk)
-	if err != nil {
-		return nil, fmt.Errorf("Compression failed: %w", err)
+	s := getScratch()
+	defer putScratch(s)
+
+	if s.compressor == nil {
+		compressor, err := compression.NewCompressor()
+		if err != nil {
+			return nil, fmt.Errorf("Compression failed: %w", err)
+		}
+		s.compressor = compressor
 	}
 
-	sizeHeader := make([]byte, 4)
-	binary.BigEndian.PutUint32(sizeHeader, uint32(len(compressedData)))
-	fullPayload := append(sizeHeader, compressedData...)
+	// Reserve the size header, then compress straight after it
+	s.payload.Write(zeros[:sizeHeaderLength])
+	if err := s.compressor.CompressTo(&s.payload, chunk); err != nil {
+		return nil, fmt.Errorf("Compression failed: %w", err)
+	}
+	binary.BigEndian.PutUint32(s.payload.Bytes(), uint32(s.payload.Len()-sizeHeaderLength))
 
 	// Pad to 16-byte boundary
-	alignedSize := (len(fullPayload) + 15) & ^15
-	paddedPayload := make([]byte, alignedSize)
-	copy(paddedPayload, fullPayload)
+	alignedSize := (s.payload.Len() + 15) & ^15
+	s.payload.Write(zeros[:alignedSize-s.payload.Len()])
 
-	aesEncrypted, err := c.AESCipher.Encrypt(paddedPayload)
+	aesEncrypted, err := c.AESCipher.Seal(s.inner[:0], s.payload.Bytes())
 	if err != nil {
 		return nil, fmt.Errorf("AES encryption failed: %w", err)
 	}
+	s.inner = aesEncrypted
 
-	chaCha20Encrypted, err := c.ChaCha20Cipher.Encrypt(aesEncrypted)
+	chaCha20Encrypted, err := c.ChaCha20Cipher.Seal(s.outer[:0], aesEncrypted)
 	if err != nil {
 		return nil, fmt.Errorf("ChaCha20 encryption failed: %w", err)
 	}
+	s.outer = chaCha20Encrypted
 
 	encoded, err := c.ReedSolomon.Encode(chaCha20Encrypted)
 	if err != nil {
@@ -0,0 +1,34 @@
+package processor
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/hambosto/go-encryption/internal/compression"
+)
+
+// scratch holds the intermediate buffers of one chunk in flight. Workers
+// borrow one per chunk from the pool, so after warm-up a chunk only
+// allocates the buffer it returns.
+type scratch struct {
+	compressor   *compression.Compressor
+	decompressor *compression.Decompressor
+	payload      bytes.Buffer
+	inner        []byte
+	outer        []byte
+}
+
+var scratchPool = sync.Pool{
+	New: func() any {
+		return &scratch{decompressor: compression.NewDecompressor()}
+	},
+}
+
+func getScratch() *scratch {
+	return scratchPool.Get().(*scratch)
+}
+
+func putScratch(s *scratch) {
+	s.payload.Reset()
+	scratchPool.Put(s)
+}
@@ -0,0 +1,101 @@
+package progress
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// minInterval limits how often progress events are written, so that small
+// chunks do not flood the consumer.
+const minInterval = 250 * time.Millisecond
+
+// Event is one line of JSON output. Rate is in bytes per second; ETA is in
+// seconds and zero when the total is unknown. File is only set for the
+// files of a batch.
+type Event struct {
+	Event     string  `json:"event"`
+	Operation string  `json:"operation"`
+	File      string  `json:"file,omitempty"`
+	BytesDone int64   `json:"bytes_done"`
+	Total     int64   `json:"total"`
+	Rate      float64 `json:"rate"`
+	ETA       float64 `json:"eta_seconds"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// JSON writes newline-delimited Event objects: "start", then "progress" at
+// most every 250ms, then "done" or "error". Write errors are ignored so that
+// a consumer going away never fails the operation itself.
+type JSON struct {
+	sink      *jsonSink
+	file      string
+	operation string
+	total     int64
+	done      int64
+	started   time.Time
+	lastEmit  time.Time
+}
+
+// jsonSink lets the reporters of concurrent files share one writer.
+type jsonSink struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+func NewJSON(w io.Writer) *JSON {
+	return &JSON{sink: &jsonSink{encoder: json.NewEncoder(w)}}
+}
+
+// ForFile returns a reporter writing to the same output whose events name
+// file, for one file of a batch.
+func (j *JSON) ForFile(file string) *JSON {
+	return &JSON{sink: j.sink, file: file}
+}
+
+func (j *JSON) Start(operation string, total int64) {
+	j.operation, j.total, j.done = operation, total, 0
+	j.started = time.Now()
+	j.lastEmit = j.started
+	j.emit("start", "")
+}
+
+func (j *JSON) Add(n int64) {
+	j.done += n
+	if time.Since(j.lastEmit) < minInterval {
+		return
+	}
+	j.lastEmit = time.Now()
+	j.emit("progress", "")
+}
+
+func (j *JSON) Finish(err error) {
+	if err != nil {
+		j.emit("error", err.Error())
+		return
+	}
+	j.emit("done", "")
+}
+
+func (j *JSON) emit(kind string, message string) {
+	event := Event{
+		Event:     kind,
+		Operation: j.operation,
+		File:      j.file,
+		BytesDone: j.done,
+		Total:     j.total,
+		Error:     message,
+	}
+
+	if elapsed := time.Since(j.started).Seconds(); elapsed > 0 {
+		event.Rate = float64(j.done) / elapsed
+	}
+	if event.Rate > 0 && j.total > j.done {
+		event.ETA = float64(j.total-j.done) / event.Rate
+	}
+
+	j.sink.mu.Lock()
+	defer j.sink.mu.Unlock()
+	_ = j.sink.encoder.Encode(event)
+}
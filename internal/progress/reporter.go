@@ -0,0 +1,52 @@
+package progress
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Reporter receives the progress of a single encryption or decryption run.
+// Start is called once before any data is processed, Add after every chunk
+// written and Finish once the run has ended, with the error it ended with.
+// All calls come from one goroutine at a time.
+type Reporter interface {
+	Start(operation string, total int64)
+	Add(n int64)
+	Finish(err error)
+}
+
+const (
+	OperationEncrypt = "encrypt"
+	OperationDecrypt = "decrypt"
+)
+
+// Silent discards all progress.
+type Silent struct{}
+
+func (Silent) Start(string, int64) {}
+func (Silent) Add(int64)           {}
+func (Silent) Finish(error)        {}
+
+// Parse builds a reporter from a command-line style spec: "bar" for the
+// terminal progress bar, "none" for silence, "json" for JSON events on
+// stderr and "json:N" for JSON events on file descriptor N.
+func Parse(spec string) (Reporter, error) {
+	switch {
+	case spec == "" || spec == "bar":
+		return NewTerminal(), nil
+	case spec == "none":
+		return Silent{}, nil
+	case spec == "json":
+		return NewJSON(os.Stderr), nil
+	case strings.HasPrefix(spec, "json:"):
+		fd, err := strconv.Atoi(strings.TrimPrefix(spec, "json:"))
+		if err != nil || fd < 0 {
+			return nil, fmt.Errorf("invalid file descriptor in progress spec %q", spec)
+		}
+		return NewJSON(os.NewFile(uintptr(fd), "progress")), nil
+	default:
+		return nil, fmt.Errorf("unknown progress mode %q, want bar, none, json or json:FD", spec)
+	}
+}
@@ -0,0 +1,56 @@
+package progress
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/schollz/progressbar/v3"
+)
+
+// Terminal draws a progress bar on stderr, which keeps stdout free for
+// data. A negative total shows a spinner instead.
+type Terminal struct {
+	bar *progressbar.ProgressBar
+}
+
+func NewTerminal() *Terminal {
+	return &Terminal{}
+}
+
+func (t *Terminal) Start(operation string, total int64) {
+	label := "Encrypting..."
+	if operation == OperationDecrypt {
+		label = "Decrypting..."
+	}
+
+	t.bar = progressbar.NewOptions64(
+		total,
+		progressbar.OptionSetWriter(os.Stderr),
+		progressbar.OptionSetDescription(label),
+		progressbar.OptionUseANSICodes(false),
+		progressbar.OptionEnableColorCodes(true),
+		progressbar.OptionShowBytes(true),
+		progressbar.OptionShowElapsedTimeOnFinish(),
+		progressbar.OptionFullWidth(),
+		progressbar.OptionSetTheme(progressbar.ThemeUnicode),
+		progressbar.OptionOnCompletion(func() {
+			fmt.Fprintln(os.Stderr)
+		}),
+	)
+}
+
+func (t *Terminal) Add(n int64) {
+	_ = t.bar.Add64(n)
+}
+
+func (t *Terminal) Finish(err error) {
+	if err != nil {
+		// Leave the bar where it stopped rather than filling it up
+		_ = t.bar.Exit()
+		return
+	}
+	// A bar that reached its total has already completed itself
+	if !t.bar.IsFinished() {
+		_ = t.bar.Finish()
+	}
+}
@@ -4,23 +4,62 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"os"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/hambosto/go-encryption/internal/core"
 )
 
-type Prompt struct{}
+type Prompt struct {
+	options []survey.AskOpt
+}
 
 func NewPrompt() *Prompt {
 	return &Prompt{}
 }
 
+// NewTTYPrompt asks on the controlling terminal rather than stdin and
+// stdout, for when those carry data.
+func NewTTYPrompt() (*Prompt, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("no terminal to prompt on: %w", err)
+	}
+	return &Prompt{options: []survey.AskOpt{survey.WithStdio(tty, tty, tty)}}, nil
+}
+
 func (p *Prompt) ConfirmOverwrite(path string) (bool, error) {
 	var result bool
 	prompt := &survey.Confirm{
 		Message: fmt.Sprintf("Output file %s already exists. Overwrite?", path),
 	}
-	err := survey.AskOne(prompt, &result)
+	err := survey.AskOne(prompt, &result, p.options...)
+	if err != nil {
+		return false, err
+	}
+	return result, nil
+}
+
+func (p *Prompt) ConfirmResume(path string) (bool, error) {
+	var result bool
+	prompt := &survey.Confirm{
+		Message: fmt.Sprintf("An interrupted run left a partial %s. Resume it?", path),
+		Default: true,
+	}
+	err := survey.AskOne(prompt, &result, p.options...)
+	if err != nil {
+		return false, err
+	}
+	return result, nil
+}
+
+func (p *Prompt) ConfirmCheckpoint() (bool, error) {
+	var result bool
+	prompt := &survey.Confirm{
+		Message: "Record progress so that an interrupted run can be resumed? Its partial output is then kept.",
+		Default: false,
+	}
+	err := survey.AskOne(prompt, &result, p.options...)
 	if err != nil {
 		return false, err
 	}
@@ -51,7 +90,7 @@ func (p *Prompt) GetPassword() (string, error) {
 		Confirm  string
 	}{}
 
-	err := survey.Ask(questions, &answers)
+	err := survey.Ask(questions, &answers, p.options...)
 	if err != nil {
 		return "", fmt.Errorf("failed to get password: %w", err)
 	}
@@ -70,7 +109,7 @@ func (p *Prompt) ConfirmDelete(path string, promptMsg string) (bool, core.Delete
 	prompt := &survey.Confirm{
 		Message: fmt.Sprintf("%s %s", promptMsg, path),
 	}
-	err := survey.AskOne(prompt, &result)
+	err := survey.AskOne(prompt, &result, p.options...)
 	if err != nil {
 		return false, "", err
 	}
@@ -87,7 +126,7 @@ func (p *Prompt) ConfirmDelete(path string, promptMsg string) (bool, core.Delete
 		Message: "Select delete type",
 		Options: deleteOptions,
 	}
-	err = survey.AskOne(deletePrompt, &deleteType)
+	err = survey.AskOne(deletePrompt, &deleteType, p.options...)
 	if err != nil {
 		return false, "", err
 	}
@@ -99,13 +138,16 @@ func (p *Prompt) GetOperation() (core.OperationType, error) {
 	operationOptions := []string{
 		string(core.Encrypt),
 		string(core.Decrypt),
+		string(core.Repair),
+		string(core.Verify),
+		string(core.Migrate),
 	}
 	var operationType string
 	prompt := &survey.Select{
 		Message: "Select Operation:",
 		Options: operationOptions,
 	}
-	err := survey.AskOne(prompt, &operationType)
+	err := survey.AskOne(prompt, &operationType, p.options...)
 	if err != nil {
 		return "", fmt.Errorf("operation selection failed: %w", err)
 	}
@@ -122,9 +164,26 @@ func (p *Prompt) SelectFile(files []string) (string, error) {
 		Message: "Select file:",
 		Options: files,
 	}
-	err := survey.AskOne(prompt, &selectedFile)
+	err := survey.AskOne(prompt, &selectedFile, p.options...)
 	if err != nil {
 		return "", fmt.Errorf("file selection failed: %w", err)
 	}
 	return selectedFile, nil
 }
+
+func (p *Prompt) SelectFiles(files []string) ([]string, error) {
+	if len(files) == 0 {
+		return nil, errors.New("no files available for selection")
+	}
+
+	var selectedFiles []string
+	prompt := &survey.MultiSelect{
+		Message: "Select files:",
+		Options: files,
+	}
+	err := survey.AskOne(prompt, &selectedFiles, append(p.options, survey.WithValidator(survey.MinItems(1)))...)
+	if err != nil {
+		return nil, fmt.Errorf("file selection failed: %w", err)
+	}
+	return selectedFiles, nil
+}
@@ -59,11 +59,18 @@ func (f *FileFinder) FindEligibleFiles(op core.OperationType) ([]string, error)
 }
 
 func (f *FileFinder) isFileEligible(path string, info os.FileInfo, op core.OperationType) bool {
-	if info.IsDir() || strings.HasPrefix(info.Name(), ".") || f.shouldSkipPath(path) {
+	if path == "." || strings.HasPrefix(info.Name(), ".") || f.shouldSkipPath(path) {
 		return false
 	}
+	// Directories are encrypted whole into a single file
+	if info.IsDir() {
+		return op == core.Encrypt && !f.shouldSkipPath(path+"/")
+	}
 	isEncrypted := strings.HasSuffix(path, ".enc")
-	return (op == core.Encrypt && !isEncrypted) || (op == core.Decrypt && isEncrypted)
+	if op == core.Encrypt {
+		return !isEncrypted
+	}
+	return isEncrypted
 }
 
 func (f *FileFinder) shouldSkipPath(path string) bool {
@@ -0,0 +1,77 @@
+package worker
+
+import (
+	"io"
+)
+
+// Checkpoint marks a point up to which the output is complete. Offsets are
+// relative to the start of the streams passed to the first Process call,
+// so a run resumed from a checkpoint keeps reporting them on the same
+// scale.
+type Checkpoint struct {
+	Chunk        uint32 `json:"chunk"`
+	BytesDone    int64  `json:"bytes_done"`
+	InputOffset  int64  `json:"input_offset"`
+	OutputOffset int64  `json:"output_offset"`
+}
+
+// CheckpointFunc is called from the writer stage with every new checkpoint.
+// It must make the output durable before recording the checkpoint; an
+// error fails the write stage.
+type CheckpointFunc func(Checkpoint) error
+
+// WithCheckpoints calls fn each time at least interval more plaintext bytes
+// have been written. Checkpoints only fall on stripe group boundaries, where
+// no chunk is held back by the container writer.
+func (ws *WorkerStream) WithCheckpoints(interval int64, fn CheckpointFunc) *WorkerStream {
+	if interval > 0 && fn != nil {
+		ws.checkpointInterval = interval
+		ws.checkpoint = fn
+	}
+	return ws
+}
+
+// WithResume continues a run from a checkpoint. The caller positions the
+// input at from.InputOffset and the output at from.OutputOffset before
+// calling Process; the total size stays that of the whole run.
+func (ws *WorkerStream) WithResume(from Checkpoint) *WorkerStream {
+	ws.resume = from
+	return ws
+}
+
+// WithResumedRecords gives a resumed encryption the body offsets of the
+// records written before the checkpoint, so that the chunk index written
+// at the end covers the whole file.
+func (ws *WorkerStream) WithResumedRecords(offsets []int64) *WorkerStream {
+	ws.resumeRecords = offsets
+	return ws
+}
+
+func (ws *WorkerStream) checkpointDue(chunks uint32, processed int64, last int64) bool {
+	if ws.checkpoint == nil || processed-last < ws.checkpointInterval {
+		return false
+	}
+	return chunks%uint32(max(ws.stripeWidth, 1)) == 0
+}
+
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.count += int64(n)
+	return n, err
+}
+
+type countingWriter struct {
+	writer io.Writer
+	count  int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.writer.Write(p)
+	c.count += int64(n)
+	return n, err
+}
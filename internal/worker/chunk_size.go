@@ -0,0 +1,25 @@
+package worker
+
+const (
+	DefaultChunkSize = 1024 * 1024
+	smallChunkSize   = 64 * 1024
+)
+
+// ChunkSizeFor picks a chunk size for an input of totalSize bytes: small
+// inputs still split into enough chunks to keep every worker busy, while
+// large inputs use bigger chunks to cut per-chunk overhead. Beyond 4 MiB
+// that overhead is negligible, and bigger chunks would only shrink the
+// window that fits the memory budget. A negative size means the size is
+// unknown.
+func ChunkSizeFor(totalSize int64) int {
+	switch {
+	case totalSize < 0:
+		return DefaultChunkSize
+	case totalSize < 8*1024*1024:
+		return smallChunkSize
+	case totalSize < 256*1024*1024:
+		return DefaultChunkSize
+	default:
+		return 4 * 1024 * 1024
+	}
+}
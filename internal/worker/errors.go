@@ -0,0 +1,54 @@
+package worker
+
+import (
+	"fmt"
+)
+
+type Stage string
+
+const (
+	StageRead    Stage = "read"
+	StageProcess Stage = "process"
+	StageWrite   Stage = "write"
+)
+
+// PipelineError is the error returned by the pipeline when one of its
+// stages fails. Only the first failure is reported; it cancels every other
+// stage.
+type PipelineError struct {
+	Stage Stage
+	Chunk uint32
+	Err   error
+}
+
+func (e *PipelineError) Error() string {
+	return fmt.Sprintf("%s stage failed at chunk %d: %v", e.Stage, e.Chunk, e.Err)
+}
+
+func (e *PipelineError) Unwrap() error {
+	return e.Err
+}
+
+// CancelledError is returned when the context passed to ProcessContext is
+// cancelled or its deadline expires. It wraps ctx.Err() and records how far
+// processing got; chunks are written strictly in order, so everything
+// before LastChunk is complete.
+type CancelledError struct {
+	BytesProcessed  int64
+	ChunksCompleted uint32
+	Err             error
+}
+
+// LastChunk returns the index of the last chunk that was fully written, or
+// -1 if none was.
+func (e *CancelledError) LastChunk() int64 {
+	return int64(e.ChunksCompleted) - 1
+}
+
+func (e *CancelledError) Error() string {
+	return fmt.Sprintf("cancelled after %d bytes, last completed chunk %d: %v", e.BytesProcessed, e.LastChunk(), e.Err)
+}
+
+func (e *CancelledError) Unwrap() error {
+	return e.Err
+}
@@ -1,29 +1,43 @@
 package worker
 
 import (
-	"encoding/binary"
+	"context"
 	"fmt"
 	"io"
-	"sync"
-	"sync/atomic"
+
+	"github.com/hambosto/go-encryption/internal/container"
+	"github.com/hambosto/go-encryption/internal/encoding"
 )
 
 func (ws *WorkerStream) writeResults(
-	writer io.Writer,
+	ctx context.Context,
+	output *countingWriter,
 	results <-chan result,
-	wg *sync.WaitGroup,
-	errChan chan<- error,
+	window <-chan struct{},
+	fail failFunc,
 ) {
-	defer wg.Done()
-
+	writer := ws.newChunkWriter(output)
 	pending := make(map[uint32]result)
-	var nextIndex uint32
+	nextIndex := ws.resume.Chunk
+	processed := ws.resume.BytesDone
+	lastCheckpoint := processed
 
-	for res := range results {
-		if res.err != nil {
-			errChan <- fmt.Errorf("processing chunk %d: %w", res.index, res.err)
+	for {
+		var res result
+		var ok bool
+		select {
+		case res, ok = <-results:
+		case <-ctx.Done():
 			return
 		}
+		if !ok {
+			break
+		}
+
+		if res.err != nil {
+			// Workers only pass errors on when salvaging
+			res = ws.salvageChunk(res)
+		}
 
 		pending[res.index] = res
 
@@ -35,99 +49,194 @@ func (ws *WorkerStream) writeResults(
 			}
 
 			if err := ws.writeChunk(writer, current); err != nil {
-				errChan <- fmt.Errorf("writing chunk %d: %w", nextIndex, err)
+				fail(StageWrite, nextIndex, err)
 				return
 			}
 
+			processed += int64(current.size)
 			delete(pending, nextIndex)
 			nextIndex++
+			ws.recordProgress(processed, nextIndex)
+
+			if ws.checkpointDue(nextIndex, processed, lastCheckpoint) {
+				err := ws.checkpoint(Checkpoint{
+					Chunk:        nextIndex,
+					BytesDone:    processed,
+					InputOffset:  current.offset,
+					OutputOffset: output.count,
+				})
+				if err != nil {
+					fail(StageWrite, nextIndex-1, fmt.Errorf("checkpoint failed: %w", err))
+					return
+				}
+				lastCheckpoint = processed
+			}
+
+			// Free the window slot so the reader can move on
+			<-window
 		}
 	}
-}
 
-func (ws *WorkerStream) writeChunk(writer io.Writer, res result) error {
-	// For encryption, we need to prefix each chunk with its size
-	if ws.processor.IsEncryption {
-		if err := ws.writeChunkSize(writer, len(res.data)); err != nil {
-			return err
-		}
+	if err := writer.Close(); err != nil {
+		fail(StageWrite, nextIndex, err)
+		return
 	}
 
-	// Write the actual data
-	if _, err := writer.Write(res.data); err != nil {
-		return fmt.Errorf("write failed: %w", err)
+	if err := ws.writeIndex(writer, processed); err != nil {
+		fail(StageWrite, nextIndex, err)
+		return
 	}
 
-	// Update the progress bar
-	if err := ws.progress.Add(res.size); err != nil {
-		return fmt.Errorf("progress update failed: %w", err)
+	if processed != ws.totalSize {
+		fail(StageWrite, nextIndex, fmt.Errorf("processed %d bytes, expected %d", processed, ws.totalSize))
 	}
+}
 
+func (ws *WorkerStream) writeChunk(writer chunkWriter, res result) error {
+	// For encryption, the container writer frames (and possibly interleaves) each chunk
+	if err := writer.WriteChunk(res.data); err != nil {
+		return err
+	}
+
+	ws.reporter.Add(int64(res.size))
 	return nil
 }
 
-func (ws *WorkerStream) writeChunkSize(writer io.Writer, size int) error {
-	var buf [4]byte
-	binary.BigEndian.PutUint32(buf[:], uint32(size))
+func (ws *WorkerStream) newChunkWriter(writer io.Writer) chunkWriter {
+	if ws.processor.IsEncryption {
+		return container.NewWriter(writer, ws.processor.ReedSolomon.TotalShards(), ws.stripeWidth).Resume(ws.resumeRecords, ws.resume.OutputOffset)
+	}
+	return plainWriter{writer: writer}
+}
 
-	if _, err := writer.Write(buf[:]); err != nil {
-		return fmt.Errorf("chunk size write failed: %w", err)
+// writeIndex appends the encrypted offsets of the body records, which lets
+// a range of the file be decrypted without walking the whole body.
+func (ws *WorkerStream) writeIndex(writer chunkWriter, size int64) error {
+	body, ok := writer.(*container.Writer)
+	if !ok {
+		return nil
 	}
 
-	return nil
+	record, err := ws.processor.ProcessChunk(container.EncodeIndex(body.Offsets(), size))
+	if err != nil {
+		return fmt.Errorf("index encryption failed: %w", err)
+	}
+	return body.WriteIndex(record)
 }
 
-func (ws *WorkerStream) readEncryptChunks(reader io.Reader, jobs chan<- job) error {
-	var index uint32
-	buffer := make([]byte, chunkSize)
+func (ws *WorkerStream) readEncryptChunks(ctx context.Context, reader io.Reader, jobs chan<- job, window chan struct{}, fail failFunc) {
+	index := ws.resume.Chunk
+	offset := ws.resume.InputOffset
 
 	for {
-		n, err := reader.Read(buffer)
+		// Wait for room in the reorder window before reading any further
+		if !acquire(ctx, window) {
+			return
+		}
+
+		// Fill whole chunks so that chunk boundaries sit at fixed plaintext offsets.
+		// The buffer goes back to the pool once a worker has encrypted it.
+		buffer := ws.getBuffer()
+		n, err := io.ReadFull(reader, buffer)
 		if err == io.EOF {
-			break
+			ws.putBuffer(buffer)
+			<-window
+			return
 		}
-		if err != nil {
-			return fmt.Errorf("read failed: %w", err)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			fail(StageRead, index, fmt.Errorf("read failed: %w", err))
+			return
 		}
-
-		// Make a copy of the data to avoid buffer reuse issues
-		data := make([]byte, n)
-		copy(data, buffer[:n])
+		data := buffer[:n]
+		offset += int64(n)
 
 		// Send job to workers
-		jobs <- job{data: data, index: atomic.LoadUint32(&index)}
-		atomic.AddUint32(&index, 1)
+		if !send(ctx, jobs, job{data: data, index: index, offset: offset}) {
+			return
+		}
+		index++
 	}
-
-	return nil
 }
 
-func (ws *WorkerStream) readDecryptChunks(reader io.Reader, jobs chan<- job) error {
-	var index uint32
-	var sizeBuf [4]byte
+func (ws *WorkerStream) readDecryptChunks(ctx context.Context, reader io.Reader, jobs chan<- job, window chan struct{}, fail failFunc) {
+	index := ws.resume.Chunk
+	input := &countingReader{reader: reader, count: ws.resume.InputOffset}
+	chunks := container.NewReader(input, ws.processor.ReedSolomon.TotalShards(), ws.stripeWidth).WithChunkLimit(ws.processor.MaxEncodedSize(ws.chunkSize)).WithShardCheck(encoding.ShardIntact)
+
+	// The container reader only reads past the record it returns when
+	// recovering a group header, and then keeps the excess, so at a group
+	// boundary this is exactly where the next one starts
+	position := func() int64 {
+		return input.count - int64(len(chunks.Buffered()))
+	}
+	start := position()
 
 	for {
-		// Read chunk size
-		_, err := io.ReadFull(reader, sizeBuf[:])
+		if ws.salvaging() {
+			next, ok := ws.checkFraming(ctx, chunks, position, index, jobs, window)
+			if !ok {
+				return
+			}
+			index = next
+
+			// Records beyond the last chunk are misnumbered ones; drop them
+			if ws.totalSize >= 0 && index >= ws.chunkCount() {
+				return
+			}
+		}
+
+		// Wait for room in the reorder window before reading any further
+		if !acquire(ctx, window) {
+			return
+		}
+
+		// Read the next chunk, de-interleaving stripe groups transparently
+		data, err := chunks.ReadChunk()
 		if err == io.EOF {
-			break
+			<-window
+			if ws.salvaging() {
+				ws.loseChunks(ctx, jobs, window, index, ws.chunkCount(), position(), fmt.Errorf("chunk is missing from the end of the file"))
+			}
+			return
+		} else if err != nil && ws.salvaging() {
+			<-window
+			next, ok := ws.resync(ctx, chunks, position, index, start, err, jobs, window)
+			if !ok {
+				return
+			}
+			index, start = next, position()
+			continue
 		} else if err != nil {
-			return fmt.Errorf("chunk size read failed: %w", err)
+			fail(StageRead, index, err)
+			return
 		}
 
-		// Decode chunk size
-		chunkLen := binary.BigEndian.Uint32(sizeBuf[:])
-
-		// Read chunk data
-		data := make([]byte, chunkLen)
-		if _, err := io.ReadFull(reader, data); err != nil {
-			return fmt.Errorf("chunk data read failed: %w", err)
+		start = position()
+		if !send(ctx, jobs, job{data: data, index: index, offset: start}) {
+			return
 		}
+		index++
+	}
+}
 
-		// Send job to workers
-		jobs <- job{data: data, index: atomic.LoadUint32(&index)}
-		atomic.AddUint32(&index, 1)
+func acquire(ctx context.Context, window chan<- struct{}) bool {
+	if ctx.Err() != nil {
+		return false
 	}
 
-	return nil
+	select {
+	case window <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func send(ctx context.Context, jobs chan<- job, j job) bool {
+	select {
+	case jobs <- j:
+		return true
+	case <-ctx.Done():
+		return false
+	}
 }
@@ -1,15 +1,34 @@
 package worker
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"sync"
 )
 
-func (ws *WorkerStream) runPipeline(reader io.Reader, writer io.Writer) error {
+// runPipeline reads chunks, processes them on workerCount goroutines and
+// writes them back in order. A chunk holds a slot of the reorder window from
+// the moment it is read until it has been written, so the reader blocks
+// once the window is full, however slow any single chunk is. See
+// MemoryLimit for the resulting bound.
+//
+// The first stage to fail cancels the shared context. Every blocking send
+// or receive also waits on that context, so the remaining stages return
+// promptly instead of blocking on a channel nobody is serving any more.
+func (ws *WorkerStream) runPipeline(parent context.Context, reader io.Reader, writer io.Writer) error {
+	ctx, cancel := context.WithCancelCause(parent)
+	defer cancel(nil)
+
+	window := make(chan struct{}, ws.windowSize())
 	jobs := make(chan job, ws.workerCount)
 	results := make(chan result, ws.workerCount)
-	errChan := make(chan error, 1)
+
+	// fail records the first error only; later causes are ignored
+	fail := func(stage Stage, chunk uint32, err error) {
+		cancel(&PipelineError{Stage: stage, Chunk: chunk, Err: err})
+	}
 
 	// Start workers
 	var workersWg sync.WaitGroup
@@ -17,21 +36,23 @@ func (ws *WorkerStream) runPipeline(reader io.Reader, writer io.Writer) error {
 	for range ws.workerCount {
 		go func() {
 			defer workersWg.Done()
-			ws.processJobs(jobs, results)
+			ws.processJobs(ctx, jobs, results, fail)
 		}()
 	}
 
 	// Start result writer goroutine
 	var writerWg sync.WaitGroup
 	writerWg.Add(1)
-	go ws.writeResults(writer, results, &writerWg, errChan)
+	go func() {
+		defer writerWg.Done()
+		ws.writeResults(ctx, &countingWriter{writer: writer, count: ws.resume.OutputOffset}, results, window, fail)
+	}()
 
 	// Read input and send jobs
-	var readErr error
 	if ws.processor.IsEncryption {
-		readErr = ws.readEncryptChunks(reader, jobs)
+		ws.readEncryptChunks(ctx, reader, jobs, window, fail)
 	} else {
-		readErr = ws.readDecryptChunks(reader, jobs)
+		ws.readDecryptChunks(ctx, reader, jobs, window, fail)
 	}
 
 	// Close jobs channel to signal workers to exit
@@ -46,31 +67,61 @@ func (ws *WorkerStream) runPipeline(reader io.Reader, writer io.Writer) error {
 	// Wait for writer to complete
 	writerWg.Wait()
 
-	// Check for errors from the write goroutine
-	select {
-	case err := <-errChan:
-		if readErr != nil {
-			// If we have both read and write errors, combine them
-			return fmt.Errorf("multiple errors: %v and %v", readErr, err)
-		}
-		return err
-	default:
-		return readErr
+	cause := context.Cause(ctx)
+	if cause == nil {
+		return nil
 	}
+
+	var stageErr *PipelineError
+	if errors.As(cause, &stageErr) || parent.Err() == nil {
+		return cause
+	}
+
+	bytes, chunks := ws.Progress()
+	return &CancelledError{BytesProcessed: bytes, ChunksCompleted: chunks, Err: parent.Err()}
 }
 
-func (ws *WorkerStream) processJobs(jobs <-chan job, results chan<- result) {
-	for j := range jobs {
-		output, err := ws.processor.ProcessChunk(j.data)
+func (ws *WorkerStream) processJobs(ctx context.Context, jobs <-chan job, results chan<- result, fail failFunc) {
+	for {
+		var j job
+		var ok bool
+		select {
+		case j, ok = <-jobs:
+			if !ok {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+
+		// A ready job and a cancellation race in the select above
+		if ctx.Err() != nil {
+			return
+		}
+
+		output, err := []byte(nil), j.err
+		if err == nil {
+			output, err = ws.processor.ProcessChunk(j.data)
+		}
+		if err == nil && !ws.processor.IsEncryption && len(output) > ws.chunkSize {
+			err = fmt.Errorf("decrypted chunk of %d bytes exceeds the chunk size of %d", len(output), ws.chunkSize)
+		}
+		if err != nil && !ws.salvaging() {
+			fail(StageProcess, j.index, err)
+			return
+		}
+
 		size := len(j.data)
-		if !ws.processor.IsEncryption {
+		if ws.processor.IsEncryption {
+			ws.putBuffer(j.data)
+		} else {
 			size = len(output)
 		}
-		results <- result{
-			index: j.index,
-			data:  output,
-			size:  size,
-			err:   err,
+
+		select {
+		case results <- result{index: j.index, data: output, size: size, offset: j.offset, err: err}:
+		case <-ctx.Done():
+			return
 		}
 	}
 }
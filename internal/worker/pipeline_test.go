@@ -0,0 +1,211 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/hambosto/go-encryption/internal/progress"
+)
+
+const testChunkSize = 4096
+
+var errInjected = errors.New("injected failure")
+
+func testKey(t testing.TB) []byte {
+	t.Helper()
+	key := make([]byte, 64)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func testData(t testing.TB, size int) []byte {
+	t.Helper()
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func newTestStream(t testing.TB, key []byte, encrypt bool) *WorkerStream {
+	t.Helper()
+	ws, err := NewWorkerStream(key, encrypt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ws.WithChunkSize(testChunkSize).WithWorkerCount(4).WithProgress(progress.Silent{})
+}
+
+// encryptTestData encrypts data and returns the body with a stream set up to
+// decrypt it.
+func encryptTestData(t *testing.T, key []byte, data []byte) ([]byte, *WorkerStream) {
+	t.Helper()
+	return encryptStripedData(t, key, data, 0)
+}
+
+func encryptStripedData(t *testing.T, key []byte, data []byte, width int) ([]byte, *WorkerStream) {
+	t.Helper()
+	enc := newTestStream(t, key, true).WithStripeWidth(width)
+	var body bytes.Buffer
+	if err := enc.Process(bytes.NewReader(data), &body, int64(len(data))); err != nil {
+		t.Fatalf("encryption failed: %v", err)
+	}
+
+	dec := newTestStream(t, key, false).WithStripeWidth(width)
+	if err := dec.SetAESNonce(enc.GetAESNonce()); err != nil {
+		t.Fatal(err)
+	}
+	if err := dec.SetChaCha20Nonce(enc.GetChaCha20Nonce()); err != nil {
+		t.Fatal(err)
+	}
+	return body.Bytes(), dec
+}
+
+// process runs fn and fails the test if it does not return in time, or if
+// it leaves pipeline goroutines behind.
+func process(t *testing.T, fn func() error) error {
+	t.Helper()
+	before := runtime.NumGoroutine()
+
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("pipeline did not return")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines left running", runtime.NumGoroutine()-before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return err
+}
+
+func assertStage(t *testing.T, err error, stage Stage, chunk uint32) {
+	t.Helper()
+	var pipelineErr *PipelineError
+	if !errors.As(err, &pipelineErr) {
+		t.Fatalf("got %v, want a *PipelineError", err)
+	}
+	if pipelineErr.Stage != stage || pipelineErr.Chunk != chunk {
+		t.Fatalf("got %s stage at chunk %d, want %s stage at chunk %d", pipelineErr.Stage, pipelineErr.Chunk, stage, chunk)
+	}
+}
+
+// failingReader returns data, then errInjected instead of io.EOF.
+type failingReader struct {
+	data []byte
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, errInjected
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+// failingWriter accepts a number of writes, then fails every one after.
+type failingWriter struct {
+	writes int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.writes == 0 {
+		return 0, errInjected
+	}
+	w.writes--
+	return len(p), nil
+}
+
+func TestPipelineReadFailure(t *testing.T) {
+	ws := newTestStream(t, testKey(t), true)
+	reader := &failingReader{data: testData(t, 3*testChunkSize)}
+
+	err := process(t, func() error {
+		return ws.Process(reader, io.Discard, 8*testChunkSize)
+	})
+	assertStage(t, err, StageRead, 3)
+	if !errors.Is(err, errInjected) {
+		t.Fatalf("got %v, want the reader's error", err)
+	}
+}
+
+func TestPipelineWriteFailure(t *testing.T) {
+	data := testData(t, 8*testChunkSize)
+	body, ws := encryptTestData(t, testKey(t), data)
+
+	// Decryption writes every chunk with a single call
+	err := process(t, func() error {
+		return ws.Process(bytes.NewReader(body), &failingWriter{writes: 2}, int64(len(data)))
+	})
+	assertStage(t, err, StageWrite, 2)
+	if !errors.Is(err, errInjected) {
+		t.Fatalf("got %v, want the writer's error", err)
+	}
+}
+
+func TestPipelineCorruptedChunk(t *testing.T) {
+	data := testData(t, 8*testChunkSize)
+	body, ws := encryptTestData(t, testKey(t), data)
+
+	// Overwrite the whole of chunk 1, far beyond what parity can rebuild
+	first := int(binary.BigEndian.Uint32(body))
+	start := 4 + first + 4
+	length := int(binary.BigEndian.Uint32(body[4+first:]))
+	copy(body[start:start+length], testData(t, length))
+
+	err := process(t, func() error {
+		return ws.Process(bytes.NewReader(body), io.Discard, int64(len(data)))
+	})
+	assertStage(t, err, StageProcess, 1)
+}
+
+// cancellingReader cancels the run once it has handed out some data, and
+// keeps supplying data after that.
+type cancellingReader struct {
+	cancel context.CancelFunc
+	reads  int
+}
+
+func (r *cancellingReader) Read(p []byte) (int, error) {
+	r.reads++
+	if r.reads == 3 {
+		r.cancel()
+	}
+	return len(p), nil
+}
+
+func TestPipelineCancel(t *testing.T) {
+	ws := newTestStream(t, testKey(t), true)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := process(t, func() error {
+		return ws.ProcessContext(ctx, &cancellingReader{cancel: cancel}, io.Discard, 1<<30)
+	})
+
+	var cancelled *CancelledError
+	if !errors.As(err, &cancelled) {
+		t.Fatalf("got %v, want a *CancelledError", err)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want it to wrap context.Canceled", err)
+	}
+}
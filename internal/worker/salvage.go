@@ -0,0 +1,171 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hambosto/go-encryption/internal/container"
+)
+
+type SalvageMode int
+
+const (
+	// SalvageOff aborts decryption at the first chunk that cannot be recovered.
+	SalvageOff SalvageMode = iota
+	// SalvageZeroFill writes zeros in place of unrecoverable chunks, so every
+	// other byte stays at its original offset.
+	SalvageZeroFill
+	// SalvageSkip leaves unrecoverable chunks out of the output entirely.
+	SalvageSkip
+)
+
+func (m SalvageMode) String() string {
+	switch m {
+	case SalvageZeroFill:
+		return "zero-fill"
+	case SalvageSkip:
+		return "skip"
+	default:
+		return "off"
+	}
+}
+
+// DamagedRange is a region of the original plaintext that could not be
+// recovered, in offsets of the original file.
+type DamagedRange struct {
+	Chunk  uint32 `json:"chunk"`
+	Offset int64  `json:"offset"`
+	Length int64  `json:"length"`
+	Error  string `json:"error"`
+}
+
+func (ws *WorkerStream) salvaging() bool {
+	return ws.salvage != SalvageOff && !ws.processor.IsEncryption
+}
+
+func (ws *WorkerStream) salvageChunk(res result) result {
+	offset := int64(res.index) * int64(ws.chunkSize)
+	length := max(min(int64(ws.chunkSize), ws.totalSize-offset), 0)
+
+	ws.damaged = append(ws.damaged, DamagedRange{
+		Chunk:  res.index,
+		Offset: offset,
+		Length: length,
+		Error:  res.err.Error(),
+	})
+
+	res.err = nil
+	res.size = int(length)
+	res.data = nil
+	if ws.salvage == SalvageZeroFill {
+		res.data = make([]byte, length)
+	}
+	return res
+}
+
+// WithRecordOffsets gives salvaging decryption the body offset of every
+// record, from the chunk index. Damaged framing is then skipped exactly;
+// without them the reader scans for the next intact record and the number
+// of chunks lost in between is estimated from the bytes skipped and the
+// average size of the records before.
+func (ws *WorkerStream) WithRecordOffsets(offsets []int64) *WorkerStream {
+	ws.records = offsets
+	return ws
+}
+
+// chunkCount is the number of chunks the plaintext splits into.
+func (ws *WorkerStream) chunkCount() uint32 {
+	if ws.totalSize < 0 {
+		return 0
+	}
+	return uint32((ws.totalSize + int64(ws.chunkSize) - 1) / int64(ws.chunkSize))
+}
+
+func (ws *WorkerStream) group() uint32 {
+	return uint32(max(ws.stripeWidth, 1))
+}
+
+// loseChunks hands the chunks from up to to on as lost, so that they are
+// reported like any other unrecoverable chunk.
+func (ws *WorkerStream) loseChunks(ctx context.Context, jobs chan<- job, window chan struct{}, from, to uint32, offset int64, cause error) bool {
+	for index := from; index < to; index++ {
+		if !acquire(ctx, window) {
+			return false
+		}
+		if !send(ctx, jobs, job{index: index, offset: offset, err: cause}) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkFraming compares the position of the record holding chunk index
+// with the chunk index, if there is one. A damaged length that still looked
+// plausible leaves the reader elsewhere; the records it skipped are lost.
+// It returns the chunk the reader is positioned at, or false if nothing
+// more can be read.
+func (ws *WorkerStream) checkFraming(ctx context.Context, chunks *container.Reader, position func() int64, index uint32, jobs chan<- job, window chan struct{}) (uint32, bool) {
+	record := int(index / ws.group())
+	if ws.records == nil || index%ws.group() != 0 || record >= len(ws.records) || position() == ws.records[record] {
+		return index, true
+	}
+	return ws.skipToRecord(ctx, chunks, position, index, record, fmt.Errorf("record does not start where the chunk index says"), jobs, window)
+}
+
+// resync moves the reader past the damaged record holding chunk index,
+// which starts at body offset start, and reports the chunks lost with it.
+// It returns the chunk the reader is positioned at, or false if nothing
+// after the damage can be read.
+func (ws *WorkerStream) resync(ctx context.Context, chunks *container.Reader, position func() int64, index uint32, start int64, cause error, jobs chan<- job, window chan struct{}) (uint32, bool) {
+	// Salvage never resumes, so the body starts at offset zero
+	read := int64(index / ws.group())
+
+	if ws.records != nil {
+		return ws.skipToRecord(ctx, chunks, position, index, int(index/ws.group())+1, cause, jobs, window)
+	}
+
+	_, err := chunks.Resync()
+	if err != nil {
+		ws.loseChunks(ctx, jobs, window, index, ws.chunkCount(), position(), cause)
+		return 0, false
+	}
+
+	// Before any record was read, only the upper bound on their size is known
+	skipped := position() - start
+	records := int64(1)
+	if read > 0 {
+		average := start / read
+		records = (skipped + average/2) / max(average, 1)
+	} else {
+		maxRecord := int64(ws.group()) * int64(ws.processor.MaxEncodedSize(ws.chunkSize)+8)
+		records = max((skipped+maxRecord-1)/maxRecord, 1)
+	}
+	next := index + uint32(records)*ws.group()
+	if total := ws.chunkCount(); ws.totalSize >= 0 && next > total {
+		next = total
+	}
+	return next, ws.loseChunks(ctx, jobs, window, index, next, position(), cause)
+}
+
+// skipToRecord moves the reader to the first record from record on that it
+// has not read into yet, and reports the chunks from index up to it as
+// lost.
+func (ws *WorkerStream) skipToRecord(ctx context.Context, chunks *container.Reader, position func() int64, index uint32, record int, cause error, jobs chan<- job, window chan struct{}) (uint32, bool) {
+	for record < len(ws.records) && ws.records[record] < position() {
+		record++
+	}
+	if record == len(ws.records) {
+		ws.loseChunks(ctx, jobs, window, index, ws.chunkCount(), position(), cause)
+		return 0, false
+	}
+
+	next := uint32(record) * ws.group()
+	if !ws.loseChunks(ctx, jobs, window, index, next, position(), cause) {
+		return 0, false
+	}
+	if err := chunks.Discard(ws.records[record] - position()); err != nil {
+		ws.loseChunks(ctx, jobs, window, next, ws.chunkCount(), position(), err)
+		return 0, false
+	}
+	return next, true
+}
@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"slices"
+	"testing"
+
+	"github.com/hambosto/go-encryption/internal/container"
+)
+
+// salvage decrypts body with zero-fill salvage and checks that exactly the
+// lost chunks are reported and zeroed, and every other chunk is intact.
+func salvage(t *testing.T, ws *WorkerStream, body []byte, data []byte, lost []uint32) {
+	t.Helper()
+	var output bytes.Buffer
+	err := process(t, func() error {
+		return ws.WithSalvage(SalvageZeroFill).Process(bytes.NewReader(body), &output, int64(len(data)))
+	})
+	if err != nil {
+		t.Fatalf("salvage failed: %v", err)
+	}
+
+	var damaged []uint32
+	for _, r := range ws.DamagedRanges() {
+		damaged = append(damaged, r.Chunk)
+	}
+	if !slices.Equal(damaged, lost) {
+		t.Fatalf("damaged chunks %v, want %v", damaged, lost)
+	}
+
+	got := output.Bytes()
+	if len(got) != len(data) {
+		t.Fatalf("got %d bytes, want %d", len(got), len(data))
+	}
+	for i := 0; i < len(data); i += testChunkSize {
+		want := data[i:min(i+testChunkSize, len(data))]
+		if slices.Contains(lost, uint32(i/testChunkSize)) {
+			want = make([]byte, len(want))
+		}
+		if !bytes.Equal(got[i:i+len(want)], want) {
+			t.Fatalf("chunk %d does not match", i/testChunkSize)
+		}
+	}
+}
+
+func recordOffsets(t *testing.T, ws *WorkerStream, body []byte) []int64 {
+	t.Helper()
+	offsets, err := container.ScanRecords(bytes.NewReader(body), ws.processor.ReedSolomon.TotalShards(), ws.stripeWidth, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return offsets
+}
+
+func TestSalvageCorruptedLength(t *testing.T) {
+	for _, indexed := range []bool{false, true} {
+		data := testData(t, 8*testChunkSize)
+		body, ws := encryptTestData(t, testKey(t), data)
+		if indexed {
+			ws.WithRecordOffsets(recordOffsets(t, ws, body))
+		}
+
+		// Shorten the length of chunk 3 to one that is still plausible
+		at := 0
+		for range 3 {
+			at += 4 + int(binary.BigEndian.Uint32(body[at:]))
+		}
+		length := binary.BigEndian.Uint32(body[at:])
+		binary.BigEndian.PutUint32(body[at:], length-14*20)
+
+		salvage(t, ws, body, data, []uint32{3})
+	}
+}
+
+func TestSalvageCorruptedGroupHeader(t *testing.T) {
+	for _, indexed := range []bool{false, true} {
+		data := testData(t, 16*testChunkSize)
+		body, ws := encryptStripedData(t, testKey(t), data, 4)
+		if indexed {
+			ws.WithRecordOffsets(recordOffsets(t, ws, body))
+		}
+
+		// Destroy both copies of the header of the second group
+		header := 4*5 + 4
+		group := func(at int) int {
+			size := 0
+			for i := range 4 {
+				size += int(binary.BigEndian.Uint32(body[at+4+4*i:]))
+			}
+			return size
+		}
+		at := 2*header + group(0)
+		copyAt := at + header + group(at)
+		copy(body[at:at+header], testData(t, header))
+		copy(body[copyAt:copyAt+header], testData(t, header))
+
+		salvage(t, ws, body, data, []uint32{4, 5, 6, 7})
+	}
+}
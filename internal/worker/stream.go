@@ -1,23 +1,54 @@
 package worker
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"runtime"
+	"sync"
 
 	"github.com/hambosto/go-encryption/internal/processor"
-	"github.com/schollz/progressbar/v3"
+	"github.com/hambosto/go-encryption/internal/progress"
 )
 
 const (
-	chunkSize      = 1024 * 1024
 	defaultWorkers = 0
+
+	// defaultWindowFactor sizes the reorder window relative to the worker
+	// count, leaving every worker a chunk to start on while others wait to
+	// be written.
+	defaultWindowFactor = 2
+
+	// defaultMemoryBudget caps the chunk data the default window may hold,
+	// so that large chunks or many workers shrink the window instead.
+	defaultMemoryBudget = 256 * 1024 * 1024
+
+	// minWindow keeps reading and processing overlapping however large the
+	// chunks are.
+	minWindow = 2
 )
 
 type WorkerStream struct {
-	processor   *processor.ChunkProcessor
-	progress    *progressbar.ProgressBar
-	workerCount int
+	processor     *processor.ChunkProcessor
+	reporter      progress.Reporter
+	workerCount   int
+	chunkSize     int
+	reorderWindow int
+	stripeWidth   int
+	totalSize     int64
+	salvage       SalvageMode
+	damaged       []DamagedRange
+	records       []int64
+
+	checkpoint         CheckpointFunc
+	checkpointInterval int64
+	resume             Checkpoint
+	resumeRecords      []int64
+
+	buffers    sync.Pool
+	progressMu sync.Mutex
+	bytesDone  int64
+	chunksDone uint32
 }
 
 func NewWorkerStream(key []byte, encrypt bool) (*WorkerStream, error) {
@@ -34,7 +65,9 @@ func NewWorkerStream(key []byte, encrypt bool) (*WorkerStream, error) {
 
 	return &WorkerStream{
 		processor:   p,
+		reporter:    progress.NewTerminal(),
 		workerCount: workerCount,
+		chunkSize:   DefaultChunkSize,
 	}, nil
 }
 
@@ -45,13 +78,139 @@ func (ws *WorkerStream) WithWorkerCount(count int) *WorkerStream {
 	return ws
 }
 
+// WithProgress replaces the default terminal progress bar.
+func (ws *WorkerStream) WithProgress(reporter progress.Reporter) *WorkerStream {
+	if reporter != nil {
+		ws.reporter = reporter
+	}
+	return ws
+}
+
+// WithChunkSize sets the plaintext chunk length. Decryption must use the
+// size the file was encrypted with, as recorded in its header.
+func (ws *WorkerStream) WithChunkSize(size int) *WorkerStream {
+	if size > 0 {
+		ws.chunkSize = size
+		ws.buffers = sync.Pool{}
+	}
+	return ws
+}
+
+// WithStripeWidth interleaves the shards of every width consecutive chunks
+// when encrypting, or tells the reader how they were interleaved when
+// decrypting. A width of zero or one keeps the contiguous layout.
+func (ws *WorkerStream) WithStripeWidth(width int) *WorkerStream {
+	if width >= 0 {
+		ws.stripeWidth = width
+	}
+	return ws
+}
+
+// WithSalvage makes decryption continue past chunks that cannot be
+// recovered instead of failing. The affected regions are reported by
+// DamagedRanges once Process returns.
+func (ws *WorkerStream) WithSalvage(mode SalvageMode) *WorkerStream {
+	ws.salvage = mode
+	return ws
+}
+
+func (ws *WorkerStream) DamagedRanges() []DamagedRange {
+	return ws.damaged
+}
+
+// WithReorderWindow caps the number of chunks that may be in flight, read
+// but not yet written, at any time. The default is twice the worker count,
+// reduced to what fits in a 256 MiB budget; a window smaller than the worker
+// count leaves workers idle.
+func (ws *WorkerStream) WithReorderWindow(chunks int) *WorkerStream {
+	if chunks > 0 {
+		ws.reorderWindow = chunks
+	}
+	return ws
+}
+
+// MemoryLimit returns the most chunk data the pipeline holds at once:
+//
+//	window × (chunk size + maxEncoded) + stripeWidth × maxEncoded
+//
+// where maxEncoded, about 3.5 × chunk size, is the size of an encrypted
+// chunk. The window defaults to 2 × workers, but no more than fits the first
+// term in 256 MiB, and no less than 2. The stripe term covers the group
+// being assembled (encryption) or de-interleaved (decryption). With 8
+// workers and 1 MiB chunks that is roughly 16 × 4.5 MiB = 72 MiB.
+func (ws *WorkerStream) MemoryLimit() int64 {
+	return int64(ws.windowSize())*ws.slotSize() + int64(ws.stripeWidth)*int64(ws.processor.MaxEncodedSize(ws.chunkSize))
+}
+
+func (ws *WorkerStream) windowSize() int {
+	if ws.reorderWindow > 0 {
+		return ws.reorderWindow
+	}
+	fits := int(defaultMemoryBudget / ws.slotSize())
+	return max(min(defaultWindowFactor*ws.workerCount, fits), minWindow)
+}
+
+// slotSize is the most memory a chunk holds while in flight.
+func (ws *WorkerStream) slotSize() int64 {
+	return int64(ws.chunkSize) + int64(ws.processor.MaxEncodedSize(ws.chunkSize))
+}
+
 func (ws *WorkerStream) Process(input io.Reader, output io.Writer, totalSize int64) error {
+	return ws.ProcessContext(context.Background(), input, output, totalSize)
+}
+
+// ProcessContext is like Process but stops all stages as soon as ctx is
+// cancelled or its deadline passes; no new chunk is started after that. The
+// returned error is then a *CancelledError wrapping ctx.Err(), so
+// errors.Is(err, context.Canceled) and errors.Is(err,
+// context.DeadlineExceeded) work as usual.
+func (ws *WorkerStream) ProcessContext(ctx context.Context, input io.Reader, output io.Writer, totalSize int64) error {
 	if input == nil || output == nil {
 		return fmt.Errorf("input and output streams must not be nil")
 	}
 
-	ws.initProgress(totalSize)
-	return ws.runPipeline(input, output)
+	ws.totalSize = totalSize
+	ws.damaged = nil
+	ws.recordProgress(ws.resume.BytesDone, ws.resume.Chunk)
+
+	operation := progress.OperationEncrypt
+	if !ws.processor.IsEncryption {
+		operation = progress.OperationDecrypt
+	}
+	ws.reporter.Start(operation, totalSize)
+	if ws.resume.BytesDone > 0 {
+		ws.reporter.Add(ws.resume.BytesDone)
+	}
+
+	err := ws.runPipeline(ctx, input, output)
+	ws.reporter.Finish(err)
+	return err
+}
+
+// Progress returns the number of plaintext bytes and chunks written so far.
+// It is safe to call while Process is running.
+func (ws *WorkerStream) Progress() (int64, uint32) {
+	ws.progressMu.Lock()
+	defer ws.progressMu.Unlock()
+	return ws.bytesDone, ws.chunksDone
+}
+
+func (ws *WorkerStream) getBuffer() []byte {
+	if buf, ok := ws.buffers.Get().(*[]byte); ok {
+		return *buf
+	}
+	return make([]byte, ws.chunkSize)
+}
+
+func (ws *WorkerStream) putBuffer(buf []byte) {
+	buf = buf[:cap(buf)]
+	ws.buffers.Put(&buf)
+}
+
+func (ws *WorkerStream) recordProgress(bytes int64, chunks uint32) {
+	ws.progressMu.Lock()
+	defer ws.progressMu.Unlock()
+	ws.bytesDone, ws.chunksDone = bytes, chunks
 }
 
 func (ws *WorkerStream) GetAESNonce() []byte {
@@ -69,21 +228,3 @@ func (ws *WorkerStream) SetAESNonce(nonce []byte) error {
 func (ws *WorkerStream) SetChaCha20Nonce(nonce []byte) error {
 	return ws.processor.ChaCha20Cipher.SetNonce(nonce)
 }
-
-func (ws *WorkerStream) initProgress(size int64) {
-	label := "Encrypting..."
-	if !ws.processor.IsEncryption {
-		label = "Decrypting..."
-	}
-
-	ws.progress = progressbar.NewOptions64(
-		size,
-		progressbar.OptionSetDescription(label),
-		progressbar.OptionUseANSICodes(false),
-		progressbar.OptionEnableColorCodes(true),
-		progressbar.OptionShowBytes(true),
-		progressbar.OptionShowElapsedTimeOnFinish(),
-		progressbar.OptionFullWidth(),
-		progressbar.OptionSetTheme(progressbar.ThemeUnicode),
-	)
-}
@@ -0,0 +1,63 @@
+package worker
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+const benchSize = 16 * 1024 * 1024
+
+// benchInput is half random and half zeros, so compression has some work
+// to do without making the input trivially small.
+func benchInput(b *testing.B) []byte {
+	b.Helper()
+	data := make([]byte, benchSize)
+	copy(data, testData(b, benchSize/2))
+	return data
+}
+
+func BenchmarkEncrypt(b *testing.B) {
+	key := testKey(b)
+	data := benchInput(b)
+
+	b.SetBytes(benchSize)
+	b.ReportAllocs()
+	for b.Loop() {
+		ws := newBenchStream(b, key, true)
+		if err := ws.Process(bytes.NewReader(data), io.Discard, benchSize); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecrypt(b *testing.B) {
+	key := testKey(b)
+	data := benchInput(b)
+
+	enc := newBenchStream(b, key, true)
+	var body bytes.Buffer
+	if err := enc.Process(bytes.NewReader(data), &body, benchSize); err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(benchSize)
+	b.ReportAllocs()
+	for b.Loop() {
+		ws := newBenchStream(b, key, false)
+		if err := ws.SetAESNonce(enc.GetAESNonce()); err != nil {
+			b.Fatal(err)
+		}
+		if err := ws.SetChaCha20Nonce(enc.GetChaCha20Nonce()); err != nil {
+			b.Fatal(err)
+		}
+		if err := ws.Process(bytes.NewReader(body.Bytes()), io.Discard, benchSize); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func newBenchStream(b *testing.B, key []byte, encrypt bool) *WorkerStream {
+	b.Helper()
+	return newTestStream(b, key, encrypt).WithChunkSize(DefaultChunkSize)
+}
@@ -1,13 +1,46 @@
 package worker
 
+import (
+	"fmt"
+	"io"
+)
+
+// offset is the input position just past the chunk, which is where a run
+// resumed after it would start reading. A job with an error stands for a
+// chunk lost to damaged framing while salvaging; it is not processed.
 type job struct {
-	data  []byte
-	index uint32
+	data   []byte
+	index  uint32
+	offset int64
+	err    error
 }
 
 type result struct {
-	index uint32
-	data  []byte
-	size  int
-	err   error
+	index  uint32
+	data   []byte
+	size   int
+	offset int64
+	err    error
+}
+
+type failFunc func(stage Stage, chunk uint32, err error)
+
+type chunkWriter interface {
+	WriteChunk(data []byte) error
+	Close() error
+}
+
+type plainWriter struct {
+	writer io.Writer
+}
+
+func (p plainWriter) WriteChunk(data []byte) error {
+	if _, err := p.writer.Write(data); err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+	return nil
+}
+
+func (p plainWriter) Close() error {
+	return nil
 }
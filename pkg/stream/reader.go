@@ -0,0 +1,164 @@
+package stream
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/hambosto/go-encryption/internal/container"
+	"github.com/hambosto/go-encryption/internal/header"
+	"github.com/hambosto/go-encryption/internal/kdf"
+	"github.com/hambosto/go-encryption/internal/processor"
+)
+
+// Reader decrypts a file written by Writer or by the command-line tool,
+// reading its input strictly sequentially. Read returns io.EOF only once
+// the end of the body has been reached and the number of bytes decrypted
+// matches the size recorded in the header or, when the header does not know
+// it, the size authenticated by the encrypted chunk index.
+type Reader struct {
+	header    header.Header
+	processor *processor.ChunkProcessor
+	body      *container.Reader
+	reader    io.Reader
+	chunkSize int
+	pending   []byte
+	read      uint64
+	short     bool
+	err       error
+}
+
+// NewDecryptReader reads the file header from r and derives the key from
+// password.
+func NewDecryptReader(r io.Reader, password []byte) (*Reader, error) {
+	fileHeader, err := header.NewHeaderReader(header.NewBinaryHeaderIO()).Read(r)
+	if err != nil {
+		return nil, fmt.Errorf("header reading failed: %w", err)
+	}
+
+	deriver, err := kdf.NewDeriver(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create KDF: %w", err)
+	}
+
+	key, err := deriver.DeriveKey(password, fileHeader.Salt.Value)
+	if err != nil {
+		return nil, fmt.Errorf("key derivation failed: %w", err)
+	}
+
+	p, err := processor.NewChunkProcessor(key, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chunk processor: %w", err)
+	}
+
+	if err := p.AESCipher.SetNonce(fileHeader.AesNonce.Value); err != nil {
+		return nil, fmt.Errorf("AES nonce setting failed: %w", err)
+	}
+
+	if err := p.ChaCha20Cipher.SetNonce(fileHeader.ChaCha20Nonce.Value); err != nil {
+		return nil, fmt.Errorf("ChaCha20 nonce setting failed: %w", err)
+	}
+
+	return &Reader{
+		header:    fileHeader,
+		processor: p,
+		body:      container.NewReader(r, p.ReedSolomon.TotalShards(), int(fileHeader.StripeWidth.Value)).WithChunkLimit(p.MaxEncodedSize(int(fileHeader.ChunkSize.Value))),
+		reader:    r,
+		chunkSize: int(fileHeader.ChunkSize.Value),
+	}, nil
+}
+
+// Size returns the plaintext size recorded in the header, or -1 if the file
+// was written without knowing it.
+func (r *Reader) Size() int64 {
+	if r.header.OriginalSize.Value == header.UnknownSize {
+		return -1
+	}
+	return int64(r.header.OriginalSize.Value)
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.err = r.next()
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// next decrypts the following chunk into pending, or verifies the size of
+// the file once the body has ended.
+func (r *Reader) next() error {
+	chunk, err := r.body.ReadChunk()
+	if err == io.EOF {
+		return r.finish()
+	}
+	if err != nil {
+		return err
+	}
+
+	plain, err := r.processor.ProcessChunk(chunk)
+	if err != nil {
+		return fmt.Errorf("chunk decryption failed: %w", err)
+	}
+
+	// Only the last chunk may be shorter than the chunk size
+	if r.short {
+		return fmt.Errorf("chunk follows a short chunk, the body is corrupted")
+	}
+	if len(plain) > r.chunkSize {
+		return fmt.Errorf("chunk of %d bytes exceeds the chunk size of %d", len(plain), r.chunkSize)
+	}
+	r.short = len(plain) < r.chunkSize
+
+	r.read += uint64(len(plain))
+	r.pending = plain
+	return nil
+}
+
+func (r *Reader) finish() error {
+	size := r.header.OriginalSize.Value
+	if size == header.UnknownSize {
+		stored, err := r.storedSize()
+		if err != nil {
+			return fmt.Errorf("size could not be authenticated: %w", err)
+		}
+		size = uint64(stored)
+	}
+
+	if r.read != size {
+		return fmt.Errorf("decrypted %d bytes, expected %d: %w", r.read, size, io.ErrUnexpectedEOF)
+	}
+	return io.EOF
+}
+
+// storedSize reads the rest of the stream, the chunk index followed by the
+// footer, and returns the size recorded in the encrypted index.
+func (r *Reader) storedSize() (int64, error) {
+	rest, err := io.ReadAll(io.MultiReader(bytes.NewReader(r.body.Buffered()), r.reader))
+	if err != nil {
+		return 0, fmt.Errorf("trailer read failed: %w", err)
+	}
+
+	trailer := len(rest) - header.EncodedSize()
+	if trailer <= 0 {
+		return 0, fmt.Errorf("chunk index is missing: %w", io.ErrUnexpectedEOF)
+	}
+
+	record, err := container.ReadIndexRecord(bytes.NewReader(rest[:trailer]), int64(trailer))
+	if err != nil {
+		return 0, err
+	}
+
+	encoded, err := r.processor.ProcessChunk(record)
+	if err != nil {
+		return 0, fmt.Errorf("index decryption failed: %w", err)
+	}
+
+	_, size, err := container.DecodeIndex(encoded)
+	return size, err
+}
@@ -0,0 +1,175 @@
+package stream
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/hambosto/go-encryption/internal/container"
+	"github.com/hambosto/go-encryption/internal/header"
+	"github.com/hambosto/go-encryption/internal/kdf"
+	"github.com/hambosto/go-encryption/internal/processor"
+	"github.com/hambosto/go-encryption/internal/worker"
+)
+
+// Options configures a Writer. The zero value, or a nil pointer, selects
+// 1 MiB chunks in the contiguous layout.
+type Options struct {
+	// ChunkSize is the plaintext chunk length, between 64 KiB and 64 MiB.
+	ChunkSize int
+	// StripeWidth interleaves the shards of that many consecutive chunks.
+	StripeWidth int
+}
+
+// Writer encrypts everything written to it into the same format as the
+// command-line tool. The size is not known upfront, so the primary header
+// records header.UnknownSize and the footer written by Close the real one.
+// Chunks are encrypted on the calling goroutine.
+type Writer struct {
+	header    header.Header
+	processor *processor.ChunkProcessor
+	body      *container.Writer
+	writer    io.Writer
+	buffer    []byte
+	written   uint64
+	closed    bool
+	err       error
+}
+
+// NewEncryptWriter derives a key from password with a fresh salt and writes
+// the file header to w. Close must be called to complete the file; it does
+// not close w.
+func NewEncryptWriter(w io.Writer, password []byte, opts *Options) (*Writer, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	chunkSize := opts.ChunkSize
+	if chunkSize == 0 {
+		chunkSize = worker.DefaultChunkSize
+	}
+	if chunkSize < header.MinChunkSize || chunkSize > header.MaxChunkSize {
+		return nil, fmt.Errorf("chunk size must be between %d and %d bytes", header.MinChunkSize, header.MaxChunkSize)
+	}
+
+	deriver, err := kdf.NewDeriver(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create KDF: %w", err)
+	}
+
+	salt, err := deriver.GenerateSalt()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key, err := deriver.DeriveKey(password, salt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	p, err := processor.NewChunkProcessor(key, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chunk processor: %w", err)
+	}
+
+	fileHeader, err := header.NewHeaderBuilder().WithSalt(salt).WithOriginalSize(header.UnknownSize).WithAesNonce(p.AESCipher.GetNonce()).WithChaCha20Nonce(p.ChaCha20Cipher.GetNonce()).WithStripeWidth(uint16(opts.StripeWidth)).WithChunkSize(uint32(chunkSize)).Build()
+	if err != nil {
+		return nil, fmt.Errorf("header building failed: %w", err)
+	}
+
+	if err := header.NewHeaderWriter(header.NewBinaryHeaderIO()).Write(w, fileHeader); err != nil {
+		return nil, fmt.Errorf("header writing failed: %w", err)
+	}
+
+	return &Writer{
+		header:    fileHeader,
+		processor: p,
+		body:      container.NewWriter(w, p.ReedSolomon.TotalShards(), opts.StripeWidth),
+		writer:    w,
+		buffer:    make([]byte, 0, chunkSize),
+	}, nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, fmt.Errorf("write to closed encrypt writer")
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	var n int
+	for len(p) > 0 {
+		taken := min(len(p), cap(w.buffer)-len(w.buffer))
+		w.buffer = append(w.buffer, p[:taken]...)
+		p = p[taken:]
+		n += taken
+
+		if len(w.buffer) == cap(w.buffer) {
+			if err := w.flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Close encrypts any buffered data and writes the end of the body, the
+// chunk index and the footer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return w.err
+	}
+	w.closed = true
+	if w.err != nil {
+		return w.err
+	}
+
+	if err := w.finish(); err != nil {
+		w.err = err
+	}
+	return w.err
+}
+
+func (w *Writer) finish() error {
+	if len(w.buffer) > 0 {
+		if err := w.flush(); err != nil {
+			return err
+		}
+	}
+
+	if err := w.body.Close(); err != nil {
+		return err
+	}
+
+	record, err := w.processor.ProcessChunk(container.EncodeIndex(w.body.Offsets(), int64(w.written)))
+	if err != nil {
+		return fmt.Errorf("index encryption failed: %w", err)
+	}
+	if err := w.body.WriteIndex(record); err != nil {
+		return err
+	}
+
+	footer := w.header
+	footer.OriginalSize = header.OriginalSize{Value: w.written}
+	if err := header.NewHeaderWriter(header.NewBinaryHeaderIO()).Write(w.writer, footer); err != nil {
+		return fmt.Errorf("backup header writing failed: %w", err)
+	}
+	return nil
+}
+
+func (w *Writer) flush() error {
+	encrypted, err := w.processor.ProcessChunk(w.buffer)
+	if err != nil {
+		w.err = fmt.Errorf("chunk encryption failed: %w", err)
+		return w.err
+	}
+
+	if err := w.body.WriteChunk(encrypted); err != nil {
+		w.err = err
+		return err
+	}
+
+	w.written += uint64(len(w.buffer))
+	w.buffer = w.buffer[:0]
+	return nil
+}